@@ -1,9 +1,11 @@
 package ptyx
 
 import (
+	"context"
 	"errors"
 	"io"
 	"os"
+	"time"
 )
 
 type Console interface {
@@ -27,11 +29,100 @@ type Session interface {
 	PtyReader() io.Reader
 	PtyWriter() io.Writer
 	Resize(cols, rows int) error
+	// Wait blocks until the child exits and returns the same result to every
+	// caller. Done is closed at that point, WaitContext gives up with the
+	// context's error if it ends first, and ExitStatus reports the exit code
+	// without blocking: -1 for failures other than an ExitError.
 	Wait() error
+	Done() <-chan struct{}
+	WaitContext(ctx context.Context) error
+	ExitStatus() (code int, exited bool)
+	State() SessionState
+	// Result reports the process's timing and resource usage once it has
+	// exited, successfully or not.
+	Result() (Result, bool)
 	Kill() error
 	Close() error
 	Pid() int
 	CloseStdin() error
+	// Signal delivers sig to the child process and SignalForeground to the
+	// terminal's foreground process group, which may be a job the child
+	// started. Signals the platform cannot deliver return ErrUnsupported.
+	Signal(sig Signal) error
+	SignalForeground(sig Signal) error
+	Interrupt(mode InterruptMode) error
+	// GetAttr and SetAttr read and change the terminal's line settings;
+	// they return ErrUnsupported on Windows.
+	GetAttr() (Termios, error)
+	SetAttr(t Termios) error
+	// EchoEnabled reports whether the terminal currently echoes input; it
+	// turns off at password prompts, and is false when the state cannot be
+	// read, so input is never mistaken for non-secret. OnEchoChange delivers
+	// the new state each time it flips, keeping only the latest value if it
+	// is not read.
+	EchoEnabled() bool
+	OnEchoChange() <-chan bool
+}
+
+// PacketEventSession is implemented by sessions that can run the pty in
+// packet mode; check for it with a type assertion. PacketEvents returns the
+// status changes seen in packet mode, or nil when SpawnOpts.PacketMode is
+// off. Events arrive as PtyReader is read and the channel is closed when
+// reading fails.
+type PacketEventSession interface {
+	PacketEvents() <-chan PacketEvent
+}
+
+// ExitFdSession is implemented by sessions that can expose the child's exit
+// as a descriptor. ExitFd returns one that becomes readable once the child
+// has exited, for polling alongside the pty master. It is a pidfd on Linux,
+// stays valid until Close, and is ErrUnsupported elsewhere.
+type ExitFdSession interface {
+	ExitFd() (uintptr, error)
+}
+
+// SandboxSession is implemented by sessions that can apply a Sandbox.
+// SandboxReport describes what SpawnOpts.Sandbox enforced, or is nil when no
+// profile was given.
+type SandboxSession interface {
+	SandboxReport() *SandboxReport
+}
+
+// CgroupSession is implemented by sessions that can run in a Cgroup.
+// CgroupStats reports the usage of the SpawnOpts.Cgroup leaf. The error wraps
+// ErrCgroupUnavailable, with the reason if placement failed.
+type CgroupSession interface {
+	CgroupStats() (CgroupStats, error)
+}
+
+// ForegroundSession is implemented by sessions that can look up the
+// terminal's foreground process group, such as an editor started from the
+// shell. OnForegroundChange delivers it each time it changes, keeping only
+// the latest value, and is closed when the child exits or the session is
+// closed. Both are Linux only: Foreground returns ErrUnsupported elsewhere
+// and the channel is nil.
+type ForegroundSession interface {
+	Foreground() (ForegroundProcess, error)
+	OnForegroundChange() <-chan ForegroundProcess
+}
+
+// As reports whether s, or a session it wraps, implements the optional
+// interface T, and returns it. A session that wraps another, such as an
+// asciicast recorder, exposes it through an Unwrap() Session method; As
+// follows that chain the way errors.As follows Unwrap on errors.
+func As[T any](s Session) (T, bool) {
+	for s != nil {
+		if t, ok := s.(T); ok {
+			return t, true
+		}
+		u, ok := s.(interface{ Unwrap() Session })
+		if !ok {
+			break
+		}
+		s = u.Unwrap()
+	}
+	var zero T
+	return zero, false
 }
 
 type SpawnOpts struct {
@@ -41,6 +132,65 @@ type SpawnOpts struct {
 	Dir  string
 	Cols int
 	Rows int
+
+	// Termios, when set, is applied to the terminal before the process
+	// starts. It is ignored on Windows.
+	Termios *Termios
+
+	// PtmxPath is the pty multiplexer to allocate from, /dev/ptmx if empty.
+	// Pointing it at the ptmx node of a private devpts mount creates the
+	// pty there. It is ignored on Windows and OpenBSD.
+	PtmxPath string
+
+	// Isolation, when set, runs the process in new Linux namespaces.
+	Isolation *Isolation
+
+	// Sandbox, when set, confines the process with rlimits, no_new_privs,
+	// seccomp and Landlock on Linux.
+	Sandbox *Sandbox
+
+	// Cgroup, when set, runs the process in a cgroup v2 leaf of its own
+	// with the given limits (Linux only).
+	Cgroup *Cgroup
+
+	// Credential, when set, runs the process as another user. HOME, USER,
+	// LOGNAME and SHELL are taken from the user's passwd entry, and the tty
+	// belongs to the user, mode 0620, until Close.
+	Credential *Credential
+
+	// LoginShell starts the program as a login shell, with "-" prefixed to
+	// its argv[0]. It is ignored on Windows.
+	LoginShell bool
+
+	// DrainOutput makes Wait return only once the child's output has been
+	// read off the pty (Unix only), holding what PtyReader has not consumed
+	// yet in memory so a Close right after Wait loses none of it. Wait stops
+	// draining after two seconds if a background job keeps the tty open.
+	// At most 1 MiB is held: past that the pty is no longer read until
+	// PtyReader catches up, so a child writing more blocks as it would with
+	// no reader, and what it has not written by Close is lost.
+	DrainOutput bool
+
+	// PacketMode turns on pty packet mode (Linux only) so flow control and
+	// flush notifications are reported through Session.PacketEvents.
+	PacketMode bool
+
+	// CancelSignal is sent to the process when the spawn context is done or
+	// Kill is called; nil means SIGKILL. If the process is still running
+	// KillDelay later it is killed outright. A zero KillDelay means five
+	// seconds and a negative one disables the escalation.
+	CancelSignal os.Signal
+	KillDelay    time.Duration
+
+	// KillDescendants makes Kill and Close on Linux also signal descendants
+	// that moved to another process group or session, found through /proc.
+	// The process group is always signalled.
+	KillDescendants bool
+
+	// Wrap, when set, is applied by Run and RunInteractive to the spawned
+	// session before it is used, e.g. to record or inspect its I/O. If it
+	// fails, the session is closed and the error returned.
+	Wrap func(Session, SpawnOpts) (Session, error)
 }
 
 type Mux interface {
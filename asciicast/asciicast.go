@@ -0,0 +1,151 @@
+// Package asciicast reads and writes terminal recordings in the asciinema
+// asciicast v2 format.
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+const Version = 2
+
+type EventType string
+
+const (
+	EventOutput EventType = "o"
+	EventInput  EventType = "i"
+	EventResize EventType = "r"
+	EventMarker EventType = "m"
+)
+
+var ErrUnsupportedVersion = errors.New("asciicast: unsupported version")
+
+type Header struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	Duration      float64           `json:"duration,omitempty"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Command       string            `json:"command,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+type Event struct {
+	Time float64
+	Type EventType
+	Data string
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{e.Time, string(e.Type), e.Data})
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("asciicast: event has %d elements, want 3", len(raw))
+	}
+	if err := json.Unmarshal(raw[0], &e.Time); err != nil {
+		return fmt.Errorf("asciicast: event time: %w", err)
+	}
+	var typ string
+	if err := json.Unmarshal(raw[1], &typ); err != nil {
+		return fmt.Errorf("asciicast: event type: %w", err)
+	}
+	e.Type = EventType(typ)
+	if err := json.Unmarshal(raw[2], &e.Data); err != nil {
+		return fmt.Errorf("asciicast: event data: %w", err)
+	}
+	return nil
+}
+
+func ResizeData(cols, rows int) string {
+	return strconv.Itoa(cols) + "x" + strconv.Itoa(rows)
+}
+
+func ParseResize(data string) (cols, rows int, err error) {
+	if _, err := fmt.Sscanf(data, "%dx%d", &cols, &rows); err != nil || cols <= 0 || rows <= 0 {
+		return 0, 0, fmt.Errorf("asciicast: bad resize data %q", data)
+	}
+	return cols, rows, nil
+}
+
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	h.Version = Version
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+func (w *Writer) WriteEvent(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(append(b, '\n'))
+	return err
+}
+
+type Reader struct {
+	Header Header
+	sc     *bufio.Scanner
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	var h Header
+	if err := json.Unmarshal(sc.Bytes(), &h); err != nil {
+		return nil, fmt.Errorf("asciicast: header: %w", err)
+	}
+	if h.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, h.Version)
+	}
+	return &Reader{Header: h, sc: sc}, nil
+}
+
+func (r *Reader) Next() (Event, error) {
+	for r.sc.Scan() {
+		line := r.sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return Event{}, err
+		}
+		return e, nil
+	}
+	if err := r.sc.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
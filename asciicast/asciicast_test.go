@@ -0,0 +1,130 @@
+package asciicast
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEventJSON(t *testing.T) {
+	e := Event{Time: 1.5, Type: EventOutput, Data: "hi\r\n\x1b[0m"}
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	if want := `[1.5,"o","hi\r\n\u001b[0m"]`; string(b) != want {
+		t.Errorf("Marshal() = %s, want %s", b, want)
+	}
+
+	var got Event
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	if got != e {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, e)
+	}
+}
+
+func TestEventJSON_Invalid(t *testing.T) {
+	for _, in := range []string{`{}`, `[1,"o"]`, `["x","o","d"]`, `[1,2,"d"]`, `[1,"o",3]`} {
+		var e Event
+		if err := json.Unmarshal([]byte(in), &e); err == nil {
+			t.Errorf("Unmarshal(%s) should fail", in)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Width: 80, Height: 24, Env: map[string]string{"TERM": "xterm"}})
+	if err != nil {
+		t.Fatalf("NewWriter() failed: %v", err)
+	}
+	if err := w.WriteEvent(Event{Time: 0.25, Type: EventResize, Data: ResizeData(100, 40)}); err != nil {
+		t.Fatalf("WriteEvent() failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	if want := `{"version":2,"width":80,"height":24,"env":{"TERM":"xterm"}}`; lines[0] != want {
+		t.Errorf("header = %s, want %s", lines[0], want)
+	}
+	if want := `[0.25,"r","100x40"]`; lines[1] != want {
+		t.Errorf("event = %s, want %s", lines[1], want)
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestNewWriter_Error(t *testing.T) {
+	if _, err := NewWriter(failWriter{}, Header{}); err == nil {
+		t.Error("NewWriter() should fail when the header cannot be written")
+	}
+}
+
+func TestReader(t *testing.T) {
+	in := `{"version":2,"width":100,"height":30,"idle_time_limit":1.5}
+[0.1,"o","hi"]
+
+[0.2,"r","120x40"]
+`
+	r, err := NewReader(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("NewReader() failed: %v", err)
+	}
+	if r.Header.Width != 100 || r.Header.Height != 30 || r.Header.IdleTimeLimit != 1.5 {
+		t.Errorf("Header = %+v", r.Header)
+	}
+	want := []Event{{Time: 0.1, Type: EventOutput, Data: "hi"}, {Time: 0.2, Type: EventResize, Data: "120x40"}}
+	for _, w := range want {
+		e, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next() failed: %v", err)
+		}
+		if e != w {
+			t.Errorf("Next() = %+v, want %+v", e, w)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next() at end = %v, want io.EOF", err)
+	}
+}
+
+func TestReader_Errors(t *testing.T) {
+	if _, err := NewReader(strings.NewReader("")); err != io.ErrUnexpectedEOF {
+		t.Errorf("NewReader(empty) = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, err := NewReader(strings.NewReader("not json\n")); err == nil {
+		t.Error("NewReader() should fail on a bad header")
+	}
+	if _, err := NewReader(strings.NewReader(`{"version":1}`)); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("NewReader(v1) = %v, want ErrUnsupportedVersion", err)
+	}
+
+	r, err := NewReader(strings.NewReader("{\"version\":2}\n[1,\"o\"]\n"))
+	if err != nil {
+		t.Fatalf("NewReader() failed: %v", err)
+	}
+	if _, err := r.Next(); err == nil {
+		t.Error("Next() should fail on a malformed event")
+	}
+}
+
+func TestParseResize(t *testing.T) {
+	cols, rows, err := ParseResize("120x40")
+	if err != nil || cols != 120 || rows != 40 {
+		t.Errorf("ParseResize() = %d, %d, %v", cols, rows, err)
+	}
+	for _, in := range []string{"", "x", "0x10", "10", "axb"} {
+		if _, _, err := ParseResize(in); err == nil {
+			t.Errorf("ParseResize(%q) should fail", in)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package asciicast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/safedep/ptyx"
+)
+
+const (
+	KeyPause = ' '
+	KeyStep  = '.'
+	KeyQuit  = 'q'
+	keyCtrlC = 0x03
+)
+
+var errQuit = errors.New("asciicast: playback stopped")
+
+type PlayOptions struct {
+	Speed         float64
+	IdleTimeLimit time.Duration
+	Controls      bool
+	Resize        bool
+}
+
+type player struct {
+	out    io.Writer
+	keys   <-chan byte
+	paused bool
+}
+
+func Play(ctx context.Context, c ptyx.Console, r io.Reader, opts PlayOptions) error {
+	rd, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	idle := opts.IdleTimeLimit
+	if idle == 0 && rd.Header.IdleTimeLimit > 0 {
+		idle = time.Duration(rd.Header.IdleTimeLimit * float64(time.Second))
+	}
+
+	p := &player{out: c.Out()}
+
+	if opts.Resize {
+		if w, h := c.Size(); w > 0 && h > 0 && (w != rd.Header.Width || h != rd.Header.Height) {
+			p.resize(rd.Header.Width, rd.Header.Height)
+			defer p.resize(w, h)
+		}
+	}
+
+	if opts.Controls {
+		if st, err := c.MakeRaw(); err == nil {
+			defer func() { _ = c.Restore(st) }()
+		}
+		keys, stop := readKeys(c.In())
+		defer stop()
+		p.keys = keys
+	}
+
+	var last float64
+	for {
+		e, err := rd.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		delay := time.Duration((e.Time - last) * float64(time.Second))
+		last = e.Time
+		if idle > 0 && delay > idle {
+			delay = idle
+		}
+		delay = time.Duration(float64(delay) / speed)
+
+		if err := p.wait(ctx, delay); err != nil {
+			if errors.Is(err, errQuit) {
+				return nil
+			}
+			return err
+		}
+
+		switch e.Type {
+		case EventOutput:
+			if _, err := io.WriteString(p.out, e.Data); err != nil {
+				return err
+			}
+		case EventResize:
+			if opts.Resize {
+				if cols, rows, err := ParseResize(e.Data); err == nil {
+					p.resize(cols, rows)
+				}
+			}
+		}
+	}
+}
+
+func (p *player) resize(cols, rows int) {
+	_, _ = fmt.Fprintf(p.out, "\x1b[8;%d;%dt", rows, cols)
+}
+
+func (p *player) wait(ctx context.Context, delay time.Duration) error {
+	if delay < 0 {
+		delay = 0
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	deadline := time.Now().Add(delay)
+	remaining := delay
+	if p.paused {
+		timer.Stop()
+	}
+
+	for {
+		var fire <-chan time.Time
+		if !p.paused {
+			fire = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-fire:
+			return nil
+		case k, ok := <-p.keys:
+			if !ok {
+				p.keys = nil
+				continue
+			}
+			switch k {
+			case KeyQuit, keyCtrlC:
+				return errQuit
+			case KeyPause:
+				if p.paused {
+					p.paused = false
+					deadline = time.Now().Add(remaining)
+					timer.Reset(remaining)
+				} else {
+					p.paused = true
+					if !timer.Stop() {
+						return nil
+					}
+					remaining = max(time.Until(deadline), 0)
+				}
+			case KeyStep:
+				if p.paused {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// readKeys forwards the bytes read from r until the returned stop function
+// is called. Stop interrupts a pending Read through SetReadDeadline when r
+// supports it, such as an *os.File on a pollable descriptor; otherwise the
+// reader is left blocked and the next byte it gets is discarded.
+func readKeys(r io.Reader) (<-chan byte, func()) {
+	ch := make(chan byte)
+	if r == nil {
+		close(ch)
+		return ch, func() {}
+	}
+	stop := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		defer close(ch)
+		b := make([]byte, 1)
+		for {
+			n, err := r.Read(b)
+			if n > 0 {
+				select {
+				case ch <- b[0]:
+				case <-stop:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return ch, func() {
+		close(stop)
+		d, ok := r.(interface{ SetReadDeadline(time.Time) error })
+		if ok && d.SetReadDeadline(time.Now()) == nil {
+			<-exited
+			_ = d.SetReadDeadline(time.Time{})
+		}
+	}
+}
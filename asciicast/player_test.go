@@ -0,0 +1,196 @@
+package asciicast
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/safedep/ptyx/testptyx"
+)
+
+func cast(header string, events ...string) string {
+	return header + "\n" + strings.Join(events, "\n") + "\n"
+}
+
+func TestPlay(t *testing.T) {
+	c := testptyx.NewMockConsole("")
+	rec := cast(`{"version":2,"width":100,"height":30}`,
+		`[0.001,"o","hello "]`,
+		`[0.002,"i","ignored"]`,
+		`[0.003,"r","120x40"]`,
+		`[0.004,"m","marker"]`,
+		`[0.005,"o","world"]`,
+	)
+	if err := Play(context.Background(), c, strings.NewReader(rec), PlayOptions{Resize: true}); err != nil {
+		t.Fatalf("Play() failed: %v", err)
+	}
+	want := "\x1b[8;30;100thello \x1b[8;40;120tworld\x1b[8;24;80t"
+	if got := c.OutBuffer.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestPlay_NoResize(t *testing.T) {
+	c := testptyx.NewMockConsole("")
+	rec := cast(`{"version":2,"width":100,"height":30}`, `[0,"r","120x40"]`, `[0,"o","x"]`)
+	if err := Play(context.Background(), c, strings.NewReader(rec), PlayOptions{}); err != nil {
+		t.Fatalf("Play() failed: %v", err)
+	}
+	if got := c.OutBuffer.String(); got != "x" {
+		t.Errorf("output = %q, want %q", got, "x")
+	}
+}
+
+func TestPlay_IdleAndSpeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		opts   PlayOptions
+	}{
+		{"idle option", `{"version":2,"width":80,"height":24}`, PlayOptions{IdleTimeLimit: time.Millisecond}},
+		{"header idle limit", `{"version":2,"width":80,"height":24,"idle_time_limit":0.001}`, PlayOptions{}},
+		{"speed", `{"version":2,"width":80,"height":24}`, PlayOptions{Speed: 1e6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testptyx.NewMockConsole("")
+			rec := cast(tt.header, `[60,"o","a"]`, `[120,"o","b"]`)
+			start := time.Now()
+			if err := Play(context.Background(), c, strings.NewReader(rec), tt.opts); err != nil {
+				t.Fatalf("Play() failed: %v", err)
+			}
+			if d := time.Since(start); d > 2*time.Second {
+				t.Errorf("Play() took %v, gaps were not shortened", d)
+			}
+			if got := c.OutBuffer.String(); got != "ab" {
+				t.Errorf("output = %q, want %q", got, "ab")
+			}
+		})
+	}
+}
+
+func playAsync(t *testing.T, rec string) (*testptyx.MockConsole, *io.PipeWriter, <-chan error) {
+	t.Helper()
+	c := testptyx.NewMockConsole("")
+	pr, pw := io.Pipe()
+	c.InReader = pr
+	t.Cleanup(func() { _ = pw.Close() })
+	done := make(chan error, 1)
+	go func() { done <- Play(context.Background(), c, strings.NewReader(rec), PlayOptions{Controls: true}) }()
+	return c, pw, done
+}
+
+func waitPlay(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Play() failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Play() did not return")
+	}
+}
+
+func TestPlay_Quit(t *testing.T) {
+	c, keys, done := playAsync(t, cast(`{"version":2}`, `[100,"o","late"]`))
+	_, _ = keys.Write([]byte("q"))
+	waitPlay(t, done)
+	if got := c.OutBuffer.String(); got != "" {
+		t.Errorf("output = %q, want nothing after quit", got)
+	}
+}
+
+func TestPlay_PauseAndStep(t *testing.T) {
+	c, keys, done := playAsync(t, cast(`{"version":2}`, `[100,"o","a"]`, `[200,"o","b"]`, `[300,"o","c"]`))
+	_, _ = keys.Write([]byte(" .."))
+	_, _ = keys.Write([]byte{0x03})
+	waitPlay(t, done)
+	if got := c.OutBuffer.String(); got != "ab" {
+		t.Errorf("output = %q, want %q", got, "ab")
+	}
+}
+
+func TestPlay_Resume(t *testing.T) {
+	c, keys, done := playAsync(t, cast(`{"version":2}`, `[0.05,"o","a"]`))
+	_, _ = keys.Write([]byte(" . "))
+	waitPlay(t, done)
+	if got := c.OutBuffer.String(); got != "a" {
+		t.Errorf("output = %q, want %q", got, "a")
+	}
+}
+
+func TestPlayerWait_PauseKeepsRemainingDelay(t *testing.T) {
+	keys := make(chan byte)
+	p := &player{keys: keys}
+	done := make(chan error, 1)
+	go func() { done <- p.wait(context.Background(), 200*time.Millisecond) }()
+	keys <- KeyPause
+	time.Sleep(300 * time.Millisecond)
+	keys <- KeyPause
+	resumed := time.Now()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("wait() = %v", err)
+		}
+		if d := time.Since(resumed); d < 100*time.Millisecond {
+			t.Errorf("wait() returned %v after resume, want the rest of the delay", d)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("wait() did not return after resume")
+	}
+}
+
+func TestReadKeys_StopReleasesReader(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+	_, stop := readKeys(pr)
+	stop()
+	_, _ = pw.Write([]byte("x"))
+	b := make([]byte, 1)
+	if n, err := pr.Read(b); n != 1 || err != nil || b[0] != 'x' {
+		t.Errorf("Read() after stop = %d, %v, %q; want the byte left for the caller", n, err, b[:n])
+	}
+}
+
+func TestPlay_KeysClosed(t *testing.T) {
+	c := testptyx.NewMockConsole("")
+	c.InReader = nil
+	rec := cast(`{"version":2}`, `[0.01,"o","a"]`)
+	if err := Play(context.Background(), c, strings.NewReader(rec), PlayOptions{Controls: true}); err != nil {
+		t.Fatalf("Play() failed: %v", err)
+	}
+	if got := c.OutBuffer.String(); got != "a" {
+		t.Errorf("output = %q, want %q", got, "a")
+	}
+}
+
+func TestPlay_Errors(t *testing.T) {
+	c := testptyx.NewMockConsole("")
+	if err := Play(context.Background(), c, strings.NewReader(""), PlayOptions{}); err == nil {
+		t.Error("Play() should fail without a header")
+	}
+	if err := Play(context.Background(), c, strings.NewReader(cast(`{"version":2}`, `bad`)), PlayOptions{}); err == nil {
+		t.Error("Play() should fail on a malformed event")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Play(ctx, c, strings.NewReader(cast(`{"version":2}`, `[10,"o","a"]`)), PlayOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Play() = %v, want context.Canceled", err)
+	}
+
+	c.ForceWriteError = errors.New("write failed")
+	if err := Play(context.Background(), c, strings.NewReader(cast(`{"version":2}`, `[0,"o","a"]`)), PlayOptions{}); err == nil {
+		t.Error("Play() should propagate write errors")
+	}
+}
@@ -0,0 +1,201 @@
+package asciicast
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/safedep/ptyx"
+)
+
+var nowFunc = time.Now
+
+type Options struct {
+	Title       string
+	Command     string
+	RecordInput bool
+}
+
+// Recorder is a Session that writes what passes through it to an asciicast
+// recording. Use ptyx.As to reach the optional interfaces of the session it
+// wraps, such as ptyx.SandboxSession.
+type Recorder struct {
+	ptyx.Session
+	w     *Writer
+	start time.Time
+	r     io.Reader
+	wr    io.Writer
+
+	mu   sync.Mutex
+	err  error
+	tail map[EventType][]byte
+}
+
+func Record(s ptyx.Session, w io.Writer, opts ptyx.SpawnOpts, o Options) (*Recorder, error) {
+	start := nowFunc()
+	cmd := o.Command
+	if cmd == "" {
+		cmd = strings.TrimSpace(opts.Prog + " " + strings.Join(opts.Args, " "))
+	}
+	cols, rows := opts.Cols, opts.Rows
+	if cols <= 0 || rows <= 0 {
+		cols, rows = 80, 24
+	}
+	aw, err := NewWriter(w, Header{
+		Width:     cols,
+		Height:    rows,
+		Timestamp: start.Unix(),
+		Command:   cmd,
+		Title:     o.Title,
+		Env:       headerEnv(opts.Env),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &Recorder{Session: s, w: aw, start: start, tail: map[EventType][]byte{}}
+	rec.r = &recordingReader{rec: rec, r: s.PtyReader()}
+	rec.wr = s.PtyWriter()
+	if o.RecordInput {
+		rec.wr = &recordingWriter{rec: rec, w: s.PtyWriter()}
+	}
+	return rec, nil
+}
+
+func Wrap(w io.Writer, o Options) func(ptyx.Session, ptyx.SpawnOpts) (ptyx.Session, error) {
+	return func(s ptyx.Session, opts ptyx.SpawnOpts) (ptyx.Session, error) {
+		rec, err := Record(s, w, opts, o)
+		if err != nil {
+			return nil, err
+		}
+		return rec, nil
+	}
+}
+
+func headerEnv(env []string) map[string]string {
+	out := map[string]string{}
+	for _, key := range []string{"TERM", "SHELL"} {
+		v, ok := lookupEnv(env, key)
+		if !ok {
+			v, ok = os.LookupEnv(key)
+		}
+		if ok {
+			out[key] = v
+		}
+	}
+	return out
+}
+
+func lookupEnv(env []string, key string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		if k, v, ok := strings.Cut(env[i], "="); ok && k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (r *Recorder) PtyReader() io.Reader { return r.r }
+func (r *Recorder) PtyWriter() io.Writer { return r.wr }
+
+// Unwrap returns the recorded session, for ptyx.As.
+func (r *Recorder) Unwrap() ptyx.Session { return r.Session }
+
+func (r *Recorder) Resize(cols, rows int) error {
+	if err := r.Session.Resize(cols, rows); err != nil {
+		return err
+	}
+	r.record(EventResize, []byte(ResizeData(cols, rows)))
+	return nil
+}
+
+// Close writes out any incomplete UTF-8 sequence still held back, with
+// U+FFFD in place of the missing bytes, and closes the session.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	for _, typ := range []EventType{EventOutput, EventInput} {
+		if p := r.tail[typ]; len(p) > 0 {
+			delete(r.tail, typ)
+			r.writeEvent(typ, strings.ToValidUTF8(string(p), "\uFFFD"))
+		}
+	}
+	r.mu.Unlock()
+	return r.Session.Close()
+}
+
+func (r *Recorder) Marker(label string) {
+	r.record(EventMarker, []byte(label))
+}
+
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(typ EventType, p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if typ == EventOutput || typ == EventInput {
+		p = append(r.tail[typ], p...)
+		n := validPrefix(p)
+		r.tail[typ] = append([]byte(nil), p[n:]...)
+		p = p[:n]
+		if len(p) == 0 {
+			return
+		}
+	}
+
+	r.writeEvent(typ, string(p))
+}
+
+func (r *Recorder) writeEvent(typ EventType, data string) {
+	e := Event{Time: nowFunc().Sub(r.start).Seconds(), Type: typ, Data: data}
+	if err := r.w.WriteEvent(e); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func validPrefix(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
+
+type recordingReader struct {
+	rec *Recorder
+	r   io.Reader
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if n > 0 {
+		rr.rec.record(EventOutput, p[:n])
+	}
+	return n, err
+}
+
+type recordingWriter struct {
+	rec *Recorder
+	w   io.Writer
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	// Input typed while echo is off is a secret (passwords, passphrases).
+	echo := rw.rec.Session.EchoEnabled()
+	n, err := rw.w.Write(p)
+	if n > 0 && echo {
+		rw.rec.record(EventInput, p[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,269 @@
+package asciicast
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/safedep/ptyx"
+	"github.com/safedep/ptyx/testptyx"
+)
+
+func fakeClock(t *testing.T) func(time.Duration) {
+	t.Helper()
+	orig := nowFunc
+	t.Cleanup(func() { nowFunc = orig })
+	now := time.Unix(1700000000, 0)
+	nowFunc = func() time.Time { return now }
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func decode(t *testing.T, data string) (Header, []Event) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	var h Header
+	if err := json.Unmarshal([]byte(lines[0]), &h); err != nil {
+		t.Fatalf("bad header %q: %v", lines[0], err)
+	}
+	var events []Event
+	for _, l := range lines[1:] {
+		var e Event
+		if err := json.Unmarshal([]byte(l), &e); err != nil {
+			t.Fatalf("bad event %q: %v", l, err)
+		}
+		events = append(events, e)
+	}
+	return h, events
+}
+
+func TestRecord(t *testing.T) {
+	advance := fakeClock(t)
+	s := testptyx.NewMockSession("")
+	pr, pw := io.Pipe()
+	s.PtyOutReader = pr
+
+	var buf bytes.Buffer
+	rec, err := Record(s, &buf, ptyx.SpawnOpts{
+		Prog: "sh",
+		Args: []string{"-c", "echo hi"},
+		Cols: 100,
+		Rows: 30,
+		Env:  []string{"TERM=xterm-256color", "SHELL=/bin/zsh"},
+	}, Options{Title: "demo", RecordInput: true})
+	if err != nil {
+		t.Fatalf("Record() failed: %v", err)
+	}
+
+	go func() {
+		_, _ = pw.Write([]byte("hé"[:2]))
+		_, _ = pw.Write([]byte("hé"[2:]))
+		_ = pw.Close()
+	}()
+
+	advance(500 * time.Millisecond)
+	out, _ := io.ReadAll(rec.PtyReader())
+	if string(out) != "hé" {
+		t.Errorf("reader output = %q, want %q", out, "hé")
+	}
+
+	advance(time.Second)
+	if _, err := rec.PtyWriter().Write([]byte("ls\r")); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if s.PtyInBuffer.String() != "ls\r" {
+		t.Errorf("session input = %q, want %q", s.PtyInBuffer.String(), "ls\r")
+	}
+	if err := rec.Resize(120, 40); err != nil {
+		t.Fatalf("Resize() failed: %v", err)
+	}
+	rec.Marker("done")
+
+	h, events := decode(t, buf.String())
+	wantHeader := Header{
+		Version:   2,
+		Width:     100,
+		Height:    30,
+		Timestamp: 1700000000,
+		Command:   "sh -c echo hi",
+		Title:     "demo",
+		Env:       map[string]string{"TERM": "xterm-256color", "SHELL": "/bin/zsh"},
+	}
+	if !reflect.DeepEqual(h, wantHeader) {
+		t.Errorf("header = %+v, want %+v", h, wantHeader)
+	}
+	wantEvents := []Event{
+		{Time: 0.5, Type: EventOutput, Data: "h"},
+		{Time: 0.5, Type: EventOutput, Data: "é"},
+		{Time: 1.5, Type: EventInput, Data: "ls\r"},
+		{Time: 1.5, Type: EventResize, Data: "120x40"},
+		{Time: 1.5, Type: EventMarker, Data: "done"},
+	}
+	if !reflect.DeepEqual(events, wantEvents) {
+		t.Errorf("events = %+v, want %+v", events, wantEvents)
+	}
+	if rec.Err() != nil {
+		t.Errorf("Err() = %v, want nil", rec.Err())
+	}
+}
+
+func TestRecord_InputNotRecordedByDefault(t *testing.T) {
+	fakeClock(t)
+	s := testptyx.NewMockSession("")
+	var buf bytes.Buffer
+	rec, err := Record(s, &buf, ptyx.SpawnOpts{Prog: "sh"}, Options{})
+	if err != nil {
+		t.Fatalf("Record() failed: %v", err)
+	}
+	_, _ = rec.PtyWriter().Write([]byte("secret\r"))
+
+	h, events := decode(t, buf.String())
+	if h.Width != 80 || h.Height != 24 {
+		t.Errorf("header size = %dx%d, want default 80x24", h.Width, h.Height)
+	}
+	if len(events) != 0 {
+		t.Errorf("events = %+v, want none", events)
+	}
+}
+
+func TestRecord_InputSkippedWhileEchoOff(t *testing.T) {
+	fakeClock(t)
+	s := testptyx.NewMockSession("")
+	var buf bytes.Buffer
+	rec, err := Record(s, &buf, ptyx.SpawnOpts{Prog: "sudo"}, Options{RecordInput: true})
+	if err != nil {
+		t.Fatalf("Record() failed: %v", err)
+	}
+
+	_, _ = rec.PtyWriter().Write([]byte("ls\r"))
+	s.SetEcho(false)
+	_, _ = rec.PtyWriter().Write([]byte("hunter2\r"))
+	s.SetEcho(true)
+	_, _ = rec.PtyWriter().Write([]byte("exit\r"))
+
+	if got := s.PtyInBuffer.String(); got != "ls\rhunter2\rexit\r" {
+		t.Errorf("session input = %q, all input must still reach the session", got)
+	}
+	_, events := decode(t, buf.String())
+	want := []Event{{Type: EventInput, Data: "ls\r"}, {Type: EventInput, Data: "exit\r"}}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %+v, want %+v", events, want)
+	}
+}
+
+type failingResize struct{ *testptyx.MockSession }
+
+func (failingResize) Resize(int, int) error { return errors.New("resize failed") }
+
+func TestRecord_ResizeErrorNotRecorded(t *testing.T) {
+	fakeClock(t)
+	var buf bytes.Buffer
+	rec, _ := Record(failingResize{testptyx.NewMockSession("")}, &buf, ptyx.SpawnOpts{}, Options{})
+	if err := rec.Resize(1, 1); err == nil {
+		t.Fatal("Resize() should propagate the session error")
+	}
+	if _, events := decode(t, buf.String()); len(events) != 0 {
+		t.Errorf("events = %+v, want none", events)
+	}
+}
+
+type limitedWriter struct {
+	n int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("disk full")
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestRecord_WriteErrors(t *testing.T) {
+	fakeClock(t)
+	if _, err := Record(testptyx.NewMockSession(""), &limitedWriter{}, ptyx.SpawnOpts{}, Options{}); err == nil {
+		t.Fatal("Record() should fail when the header cannot be written")
+	}
+
+	rec, err := Record(testptyx.NewMockSession("out"), &limitedWriter{n: 1}, ptyx.SpawnOpts{}, Options{})
+	if err != nil {
+		t.Fatalf("Record() failed: %v", err)
+	}
+	if out, _ := io.ReadAll(rec.PtyReader()); string(out) != "out" {
+		t.Errorf("output = %q, recording errors must not affect the session", out)
+	}
+	if rec.Err() == nil {
+		t.Error("Err() = nil, want the write error")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	fakeClock(t)
+	s := testptyx.NewMockSession("x")
+
+	var buf bytes.Buffer
+	wrapped, err := Wrap(&buf, Options{})(s, ptyx.SpawnOpts{Cols: 10, Rows: 5})
+	if _, ok := wrapped.(*Recorder); !ok || err != nil {
+		t.Fatalf("Wrap() returned %T, %v; want *Recorder", wrapped, err)
+	}
+
+	if got, err := Wrap(&limitedWriter{}, Options{})(s, ptyx.SpawnOpts{}); got != nil || err == nil {
+		t.Errorf("Wrap() = %v, %v; want the error when recording cannot start", got, err)
+	}
+}
+
+func TestRecord_CloseFlushesPartialRune(t *testing.T) {
+	fakeClock(t)
+	var buf bytes.Buffer
+	rec, err := Record(testptyx.NewMockSession("a\xe2\x82"), &buf, ptyx.SpawnOpts{}, Options{})
+	if err != nil {
+		t.Fatalf("Record() failed: %v", err)
+	}
+	_, _ = io.ReadAll(rec.PtyReader())
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	_ = rec.Close()
+	_, events := decode(t, buf.String())
+	if len(events) != 2 || events[0].Data != "a" || events[1].Data != "\uFFFD" {
+		t.Errorf("events = %+v, want \"a\" then U+FFFD", events)
+	}
+}
+
+func TestValidPrefix(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want int
+	}{
+		{[]byte("abc"), 3},
+		{[]byte("a\xc3"), 1},
+		{[]byte("a\xe2\x9c"), 1},
+		{[]byte("a\xe2\x9c\x93"), 4},
+		{[]byte("\xff"), 1},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := validPrefix(tt.in); got != tt.want {
+			t.Errorf("validPrefix(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRecorder_Unwrap(t *testing.T) {
+	s := testptyx.NewMockSession("")
+	s.Sandbox = &ptyx.SandboxReport{}
+	rec, err := Record(s, io.Discard, ptyx.SpawnOpts{Prog: "sh"}, Options{})
+	if err != nil {
+		t.Fatalf("Record() failed: %v", err)
+	}
+	if sb, ok := ptyx.As[ptyx.SandboxSession](rec); !ok || sb.SandboxReport() != s.Sandbox {
+		t.Errorf("As[SandboxSession](rec) = %v, %v; want the recorded session's report", sb, ok)
+	}
+	if _, ok := ptyx.As[ptyx.ForegroundSession](rec); ok {
+		t.Error("As[ForegroundSession](rec) = true for a session without it")
+	}
+}
@@ -0,0 +1,38 @@
+package ptyx
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCgroupUnavailable is returned by Session.CgroupStats when the session
+// has no cgroup, wrapping the reason when SpawnOpts.Cgroup could not be
+// applied.
+var ErrCgroupUnavailable = errors.New("ptyx: cgroup unavailable")
+
+// Cgroup places the process in a cgroup v2 leaf of its own (Linux only),
+// which Close kills and removes along with every descendant. The leaf is
+// created under Parent, or under the caller's own cgroup if empty, which
+// must be delegated to the caller. Limits that are zero are not set. When
+// the cgroup cannot be set up the process starts without it.
+type Cgroup struct {
+	Parent string
+	// Name of the leaf; a unique name is generated if empty.
+	Name string
+
+	MemoryMax int64 // bytes, memory.max
+	// CPUQuota is the CPU time allowed every CPUPeriod, 100ms if zero.
+	CPUQuota  time.Duration
+	CPUPeriod time.Duration
+	PidsMax   int64
+}
+
+// CgroupStats is the live usage of a session's cgroup, or its last usage
+// once Close has removed it. Counters the kernel does not provide are zero.
+type CgroupStats struct {
+	Path          string
+	MemoryCurrent uint64
+	MemoryPeak    uint64
+	CPUUsage      time.Duration
+	Pids          uint64
+}
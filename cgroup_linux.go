@@ -0,0 +1,334 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// cgroupMounts are where cgroup2 is looked for: unified, then hybrid.
+var cgroupMounts = []string{"/sys/fs/cgroup", "/sys/fs/cgroup/unified"}
+
+var (
+	procSelfCgroup      = "/proc/self/cgroup"
+	cgroupRemoveTimeout = 2 * time.Second
+	cgroupSeq           atomic.Uint64
+)
+
+type cgroup struct {
+	path string
+	dir  *os.File
+	err  error
+
+	mu      sync.Mutex
+	removed bool
+	last    CgroupStats
+}
+
+// attachCgroup creates the leaf and has cmd start inside it through
+// clone3, so not even the first instruction runs elsewhere. Failures are
+// kept as the reason CgroupStats reports.
+func attachCgroup(cmd *exec.Cmd, cg *Cgroup) *cgroup {
+	if cg == nil {
+		return nil
+	}
+	path, err := createCgroup(*cg)
+	if err != nil {
+		return &cgroup{err: fmt.Errorf("%w: %w", ErrCgroupUnavailable, err)}
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		_ = os.Remove(path)
+		return &cgroup{err: fmt.Errorf("%w: %w", ErrCgroupUnavailable, err)}
+	}
+	cmd.SysProcAttr.UseCgroupFD, cmd.SysProcAttr.CgroupFD = true, int(dir.Fd())
+	return &cgroup{path: path, dir: dir}
+}
+
+// start runs cmd.Start with the child placed in the leaf. The placement
+// itself can fail the clone: kernels before 5.7 have no CLONE_INTO_CGROUP
+// (ENOSYS), and the cgroup can refuse members (EBUSY, EACCES, EINVAL). The
+// child is then started outside the cgroup from a copy of cmd, which is
+// returned, and CgroupStats reports why. Any other error, such as a missing
+// program, is returned as is.
+func (c *cgroup) start(ctx context.Context, cmd *exec.Cmd) (*exec.Cmd, error) {
+	if c == nil || c.dir == nil {
+		return cmd, cmd.Start()
+	}
+	err := cmd.Start()
+	_ = c.dir.Close()
+	c.dir = nil
+	if err == nil {
+		return cmd, nil
+	}
+	c.removed = true
+	_ = os.Remove(c.path)
+	if !isPlacementError(err) {
+		return cmd, err
+	}
+
+	retry := cloneCmd(ctx, cmd)
+	retry.SysProcAttr.UseCgroupFD, retry.SysProcAttr.CgroupFD = false, 0
+	if retryErr := retry.Start(); retryErr != nil {
+		return cmd, retryErr
+	}
+	// A Cancel set through CommandContext kills cmd.Process.
+	cmd.Process = retry.Process
+	c.err = fmt.Errorf("%w: start in %s: %w", ErrCgroupUnavailable, c.path, err)
+	return retry, nil
+}
+
+func isPlacementError(err error) bool {
+	for _, errno := range []syscall.Errno{unix.EBUSY, unix.EACCES, unix.ENOSYS, unix.EINVAL} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneCmd copies every setting of cmd into a Cmd that has not been
+// started, as a Cmd cannot be started twice. The context CommandContext
+// keeps is unexported, so one with Cancel set is rebuilt on ctx.
+func cloneCmd(ctx context.Context, cmd *exec.Cmd) *exec.Cmd {
+	c := &exec.Cmd{}
+	if cmd.Cancel != nil {
+		c = exec.CommandContext(ctx, cmd.Path)
+	}
+	c.Path, c.Args, c.Env, c.Dir, c.Err = cmd.Path, cmd.Args, cmd.Env, cmd.Dir, cmd.Err
+	c.Stdin, c.Stdout, c.Stderr, c.ExtraFiles = cmd.Stdin, cmd.Stdout, cmd.Stderr, cmd.ExtraFiles
+	c.Cancel, c.WaitDelay = cmd.Cancel, cmd.WaitDelay
+	if cmd.SysProcAttr != nil {
+		attr := *cmd.SysProcAttr
+		c.SysProcAttr = &attr
+	}
+	return c
+}
+
+func createCgroup(cg Cgroup) (string, error) {
+	parent := cg.Parent
+	if parent == "" {
+		var err error
+		if parent, err = ownCgroup(); err != nil {
+			return "", err
+		}
+	}
+	var st unix.Statfs_t
+	if err := unix.Statfs(parent, &st); err != nil {
+		return "", err
+	}
+	if st.Type != unix.CGROUP2_SUPER_MAGIC {
+		return "", fmt.Errorf("%s is not on a cgroup2 filesystem", parent)
+	}
+
+	limits := cgroupLimits(cg)
+	if err := enableControllers(parent, limits); err != nil {
+		return "", err
+	}
+
+	name := cg.Name
+	if name == "" {
+		name = fmt.Sprintf("ptyx-%d-%d", os.Getpid(), cgroupSeq.Add(1))
+	}
+	path := filepath.Join(parent, name)
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return "", err
+	}
+	for file, v := range limits {
+		if err := os.WriteFile(filepath.Join(path, file), []byte(v), 0); err != nil {
+			_ = os.Remove(path)
+			return "", fmt.Errorf("set %s: %w", file, err)
+		}
+	}
+	if err := unix.Access(filepath.Join(path, "cgroup.procs"), unix.W_OK); err != nil {
+		_ = os.Remove(path)
+		return "", fmt.Errorf("%s is not delegated: %w", path, err)
+	}
+	return path, nil
+}
+
+// cgroupLimits maps the limits that are set to their interface files.
+func cgroupLimits(cg Cgroup) map[string]string {
+	limits := map[string]string{}
+	if cg.MemoryMax > 0 {
+		limits["memory.max"] = strconv.FormatInt(cg.MemoryMax, 10)
+	}
+	if cg.CPUQuota > 0 {
+		period := cg.CPUPeriod
+		if period <= 0 {
+			period = 100 * time.Millisecond
+		}
+		limits["cpu.max"] = fmt.Sprintf("%d %d", cg.CPUQuota.Microseconds(), period.Microseconds())
+	}
+	if cg.PidsMax > 0 {
+		limits["pids.max"] = strconv.FormatInt(cg.PidsMax, 10)
+	}
+	return limits
+}
+
+// ownCgroup finds the caller's cgroup v2 directory.
+func ownCgroup() (string, error) {
+	b, err := os.ReadFile(procSelfCgroup)
+	if err != nil {
+		return "", err
+	}
+	var rel string
+	found := false
+	for _, line := range strings.Split(string(b), "\n") {
+		if p, ok := strings.CutPrefix(line, "0::"); ok {
+			rel, found = p, true
+		}
+	}
+	if !found {
+		return "", errors.New("no cgroup v2 hierarchy")
+	}
+	for _, mnt := range cgroupMounts {
+		var st unix.Statfs_t
+		if unix.Statfs(mnt, &st) == nil && st.Type == unix.CGROUP2_SUPER_MAGIC {
+			return filepath.Join(mnt, rel), nil
+		}
+	}
+	return "", errors.New("cgroup2 is not mounted")
+}
+
+// enableControllers turns on in parent the controllers the limits need.
+func enableControllers(parent string, limits map[string]string) error {
+	b, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	enabled := strings.Fields(string(b))
+	for file := range limits {
+		ctl, _, _ := strings.Cut(file, ".")
+		if slices.Contains(enabled, ctl) {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+"+ctl), 0); err != nil {
+			if errors.Is(err, unix.EBUSY) && hasProcs(parent) {
+				return fmt.Errorf("enable the %s controller in %s: it has member processes (the cgroup v2 no internal processes rule); set Cgroup.Parent to an empty delegated cgroup: %w", ctl, parent, err)
+			}
+			return fmt.Errorf("enable the %s controller in %s: %w", ctl, parent, err)
+		}
+		enabled = append(enabled, ctl)
+	}
+	return nil
+}
+
+// hasProcs reports whether processes are members of the cgroup at path,
+// which the no internal processes rule forbids once controllers are on.
+func hasProcs(path string) bool {
+	b, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+	return err == nil && len(bytes.TrimSpace(b)) > 0
+}
+
+func (c *cgroup) stats() (CgroupStats, error) {
+	if c == nil {
+		return CgroupStats{}, ErrCgroupUnavailable
+	}
+	if c.err != nil {
+		return CgroupStats{}, c.err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.removed {
+		return c.last, nil
+	}
+	return c.read(), nil
+}
+
+func (c *cgroup) read() CgroupStats {
+	st := CgroupStats{Path: c.path}
+	st.MemoryCurrent, _ = c.readUint("memory.current")
+	st.MemoryPeak, _ = c.readUint("memory.peak")
+	if n, err := c.readUint("pids.current"); err == nil {
+		st.Pids = n
+	} else if b, err := os.ReadFile(filepath.Join(c.path, "cgroup.procs")); err == nil {
+		st.Pids = uint64(bytes.Count(b, []byte("\n")))
+	}
+	if f, err := os.Open(filepath.Join(c.path, "cpu.stat")); err == nil {
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if v, ok := strings.CutPrefix(sc.Text(), "usage_usec "); ok {
+				us, _ := strconv.ParseInt(v, 10, 64)
+				st.CPUUsage = time.Duration(us) * time.Microsecond
+			}
+		}
+		_ = f.Close()
+	}
+	return st
+}
+
+func (c *cgroup) readUint(file string) (uint64, error) {
+	b, err := os.ReadFile(filepath.Join(c.path, file))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// removeIfEmpty removes the cgroup once nothing is left in it, so a caller
+// that only waits for the child does not leave it behind. Jobs still running
+// in it keep it until Close.
+func (c *cgroup) removeIfEmpty() {
+	if c == nil || c.err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.removed {
+		return
+	}
+	last := c.read()
+	if err := os.Remove(c.path); err == nil || errors.Is(err, os.ErrNotExist) {
+		c.removed, c.last = true, last
+	}
+}
+
+// remove kills everything left in the cgroup and removes it, keeping its
+// final usage for CgroupStats.
+func (c *cgroup) remove() {
+	if c == nil || c.err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.removed {
+		return
+	}
+	c.removed = true
+	c.last = c.read()
+	if err := os.WriteFile(filepath.Join(c.path, "cgroup.kill"), []byte("1"), 0); err != nil {
+		// Before Linux 5.14: kill the members one by one.
+		b, _ := os.ReadFile(filepath.Join(c.path, "cgroup.procs"))
+		for _, f := range strings.Fields(string(b)) {
+			if pid, err := strconv.Atoi(f); err == nil {
+				_ = syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+	}
+	// Killed tasks leave the cgroup as they exit, shortly after the signal.
+	deadline := time.Now().Add(cgroupRemoveTimeout)
+	for {
+		err := os.Remove(c.path)
+		if err == nil || errors.Is(err, os.ErrNotExist) || !errors.Is(err, unix.EBUSY) || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,236 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// spawnInCgroup starts a session in a fresh cgroup, skipping the test when
+// this host does not delegate one to us.
+func spawnInCgroup(t *testing.T, cg Cgroup, launcher string) (Session, int, CgroupStats) {
+	t.Helper()
+	s, bg := spawnWithBackgroundJob(t, launcher, SpawnOpts{Cgroup: &cg})
+	st, err := s.(CgroupSession).CgroupStats()
+	if err != nil {
+		_ = s.Close()
+		t.Skipf("no cgroup: %v", err)
+	}
+	return s, bg, st
+}
+
+func TestCgroup_Placement(t *testing.T) {
+	s, bg, st := spawnInCgroup(t, Cgroup{}, "")
+	defer s.Close()
+	if !strings.HasPrefix(filepath.Base(st.Path), "ptyx-") {
+		t.Errorf("Path = %q, want a generated leaf", st.Path)
+	}
+	procs, err := os.ReadFile(filepath.Join(st.Path, "cgroup.procs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pid := range []int{s.Pid(), bg} {
+		if !strings.Contains("\n"+string(procs), "\n"+strconv.Itoa(pid)+"\n") {
+			t.Errorf("pid %d not in %s: %q", pid, st.Path, procs)
+		}
+	}
+	if st.Pids < 2 {
+		t.Errorf("Pids = %d, want at least the shell and its job", st.Pids)
+	}
+}
+
+func TestCgroup_CloseKillsAndRemoves(t *testing.T) {
+	s, bg, st := spawnInCgroup(t, Cgroup{Name: "ptyx-test-close"}, "setsid")
+	if filepath.Base(st.Path) != "ptyx-test-close" {
+		t.Errorf("Path = %q, want the configured name", st.Path)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	waitGone(t, bg)
+	_ = waitTimeout(t, s, 3*time.Second)
+	if _, err := os.Stat(st.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("cgroup %s still exists: %v", st.Path, err)
+	}
+	last, err := s.(CgroupSession).CgroupStats()
+	if err != nil || last.Path != st.Path || last.Pids == 0 {
+		t.Errorf("CgroupStats() after Close = %+v, %v; want the final usage", last, err)
+	}
+}
+
+func TestCgroup_WaitRemovesEmptyLeaf(t *testing.T) {
+	s, err := Spawn(context.Background(), SpawnOpts{Prog: "true", Cgroup: &Cgroup{}})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	go func() { _, _ = io.Copy(io.Discard, s.PtyReader()) }()
+	st, err := s.(CgroupSession).CgroupStats()
+	if err != nil {
+		_ = s.Close()
+		t.Skipf("no cgroup: %v", err)
+	}
+	_ = waitTimeout(t, s, 3*time.Second)
+	if _, err := os.Stat(st.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("cgroup %s still exists after Wait: %v", st.Path, err)
+	}
+	_ = s.Close()
+}
+
+func TestCgroup_Degrades(t *testing.T) {
+	dir := t.TempDir()
+	s := spawnReady(t, context.Background(), SpawnOpts{
+		Prog:   "sh",
+		Args:   []string{"-c", "echo ready"},
+		Cgroup: &Cgroup{Parent: dir},
+	})
+	_ = waitTimeout(t, s, 3*time.Second)
+	_, err := s.(CgroupSession).CgroupStats()
+	if !errors.Is(err, ErrCgroupUnavailable) || !strings.Contains(err.Error(), "cgroup2") {
+		t.Errorf("CgroupStats() = %v, want ErrCgroupUnavailable naming the reason", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("leftovers in %s: %v", dir, entries)
+	}
+	_ = s.Close()
+
+	s = spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo ready"}})
+	if _, err := s.(CgroupSession).CgroupStats(); !errors.Is(err, ErrCgroupUnavailable) {
+		t.Errorf("CgroupStats() without a cgroup = %v, want ErrCgroupUnavailable", err)
+	}
+}
+
+func TestCgroup_PidsMaxWrittenForSpawn(t *testing.T) {
+	s, _, st := spawnInCgroup(t, Cgroup{}, "")
+	parent := filepath.Dir(st.Path)
+	_ = s.Close()
+	ctl, _ := os.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+	if !strings.Contains(string(ctl), "pids") {
+		s, _ := Spawn(context.Background(), SpawnOpts{Prog: "true", Cgroup: &Cgroup{PidsMax: 8}})
+		defer s.Close()
+		if _, err := s.(CgroupSession).CgroupStats(); err == nil || !strings.Contains(err.Error(), "pids controller") {
+			t.Errorf("CgroupStats() = %v, want the missing pids controller reported", err)
+		}
+		t.Skip("pids controller unavailable")
+	}
+	s, _, st = spawnInCgroup(t, Cgroup{PidsMax: 8}, "")
+	defer s.Close()
+	if b, _ := os.ReadFile(filepath.Join(st.Path, "pids.max")); strings.TrimSpace(string(b)) != "8" {
+		t.Errorf("pids.max = %q, want 8", b)
+	}
+}
+
+func TestCgroupLimits_ControlFileValues(t *testing.T) {
+	got := cgroupLimits(Cgroup{MemoryMax: 1 << 20, CPUQuota: 50 * time.Millisecond, PidsMax: 16})
+	want := map[string]string{"memory.max": "1048576", "cpu.max": "50000 100000", "pids.max": "16"}
+	if len(got) != len(want) {
+		t.Fatalf("cgroupLimits() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if got := cgroupLimits(Cgroup{CPUQuota: time.Second, CPUPeriod: time.Second}); got["cpu.max"] != "1000000 1000000" {
+		t.Errorf("cpu.max = %q", got["cpu.max"])
+	}
+	if got := cgroupLimits(Cgroup{}); len(got) != 0 {
+		t.Errorf("cgroupLimits() = %v, want none", got)
+	}
+}
+
+func TestOwnCgroup_NoV2(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "cgroup")
+	if err := os.WriteFile(f, []byte("4:memory:/x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := procSelfCgroup
+	procSelfCgroup = f
+	defer func() { procSelfCgroup = old }()
+	if _, err := ownCgroup(); err == nil {
+		t.Error("ownCgroup() should fail without a cgroup v2 entry")
+	}
+	s := &unixSession{cgroup: attachCgroup(nil, &Cgroup{})}
+	if _, err := s.CgroupStats(); !errors.Is(err, ErrCgroupUnavailable) {
+		t.Errorf("CgroupStats() = %v, want ErrCgroupUnavailable", err)
+	}
+}
+
+func TestCgroup_StartRetriesWithoutCgroup(t *testing.T) {
+	cmd := exec.Command("true")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	c := attachCgroup(cmd, &Cgroup{})
+	if c.err != nil {
+		t.Skipf("no cgroup: %v", c.err)
+	}
+	// With a controller enabled for a child, the no internal processes rule
+	// makes the leaf refuse members (EBUSY), as an old kernel refuses the
+	// clone.
+	ctl, _ := os.ReadFile(filepath.Join(c.path, "cgroup.controllers"))
+	names := strings.Fields(string(ctl))
+	if len(names) == 0 {
+		_ = c.dir.Close()
+		_ = os.Remove(c.path)
+		t.Skip("no controller to enable in the leaf")
+	}
+	child := filepath.Join(c.path, "child")
+	if err := os.Mkdir(child, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(c.path)
+	defer os.Remove(child)
+	if err := os.WriteFile(filepath.Join(c.path, "cgroup.subtree_control"), []byte("+"+names[0]), 0); err != nil {
+		t.Skipf("enable %s: %v", names[0], err)
+	}
+	started, err := c.start(context.Background(), cmd)
+	if err != nil {
+		t.Fatalf("start() = %v, want the child started outside the cgroup", err)
+	}
+	if started == cmd || started.Process == nil {
+		t.Errorf("start() should return the retried command")
+	}
+	_ = started.Wait()
+	if _, err := c.stats(); !errors.Is(err, ErrCgroupUnavailable) || !strings.Contains(err.Error(), c.path) {
+		t.Errorf("stats() = %v, want ErrCgroupUnavailable with the placement failure", err)
+	}
+
+	cmd = exec.Command("/nonexistent")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	c = attachCgroup(cmd, &Cgroup{})
+	if started, err := c.start(context.Background(), cmd); !errors.Is(err, os.ErrNotExist) || started != cmd {
+		t.Errorf("start() = %v, want the exec error without a retry", err)
+	}
+	if _, err := os.Stat(c.path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("leaf %s left behind: %v", c.path, err)
+	}
+}
+
+func TestHasProcs(t *testing.T) {
+	dir := t.TempDir()
+	if hasProcs(dir) {
+		t.Error("hasProcs() = true without cgroup.procs")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte("42\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !hasProcs(dir) {
+		t.Error("hasProcs() = false with a member")
+	}
+}
+
+func TestCgroup_Isolated(t *testing.T) {
+	out, _ := runSandboxed(t, SpawnOpts{Cgroup: &Cgroup{Name: "ptyx-test-isolated"}, Isolation: &Isolation{User: true, PID: true}},
+		"grep -c ptyx-test-isolated /proc/self/cgroup")
+	if out != "1\n" {
+		t.Skipf("not placed in the cgroup: %q", out)
+	}
+}
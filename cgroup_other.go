@@ -0,0 +1,31 @@
+//go:build unix && !linux
+
+package ptyx
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+type cgroup struct{ err error }
+
+func attachCgroup(_ *exec.Cmd, cg *Cgroup) *cgroup {
+	if cg == nil {
+		return nil
+	}
+	return &cgroup{err: fmt.Errorf("%w: %w", ErrCgroupUnavailable, ErrUnsupported)}
+}
+
+func (c *cgroup) start(_ context.Context, cmd *exec.Cmd) (*exec.Cmd, error) {
+	return cmd, cmd.Start()
+}
+func (c *cgroup) remove()        {}
+func (c *cgroup) removeIfEmpty() {}
+
+func (c *cgroup) stats() (CgroupStats, error) {
+	if c == nil {
+		return CgroupStats{}, ErrCgroupUnavailable
+	}
+	return CgroupStats{}, c.err
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -49,6 +50,26 @@ func TestProcessStream(t *testing.T) {
 			input: "\x1b]",
 			want:  "[EVENT:UNHANDLED] \"]\"\n",
 		},
+		{
+			name:  "Tab and backspace stay in text",
+			input: "a\tb\bc\n",
+			want:  "[EVENT:TEXT] \"a\\tb\\bc\"\n[EVENT:CONTROL] \"\\n\"\n",
+		},
+		{
+			name:  "OSC title",
+			input: "\x1b]0;my title\x07done",
+			want:  "[EVENT:OSC] \"0;my title\"\n[EVENT:TEXT] \"done\"\n",
+		},
+		{
+			name:  "DCS and ESC sequences",
+			input: "\x1bP1$r0m\x1b\\\x1b7",
+			want:  "[EVENT:DCS] \"1$r0m\"\n[EVENT:ESC] \"7\"\n",
+		},
+		{
+			name:  "SS3 key",
+			input: "\x1bOA",
+			want:  "[EVENT:ESC] \"OA\"\n",
+		},
 		{
 			name:  "Escape at EOF",
 			input: "hello\x1b",
@@ -68,6 +89,30 @@ func TestProcessStream(t *testing.T) {
 	}
 }
 
+func TestProcessStream_SplitReads(t *testing.T) {
+	r := &chunkReader{chunks: []string{"h\xc3", "\xa9llo\x1b[3", "1m\x1b]0;t", "\x07"}}
+	var out bytes.Buffer
+	processStream(&out, r)
+
+	want := "[EVENT:TEXT] \"héllo\"\n[EVENT:ANSI] \"31m\"\n[EVENT:OSC] \"0;t\"\n"
+	if got := out.String(); got != want {
+		t.Errorf("processStream() output = %q, want %q", got, want)
+	}
+}
+
+type chunkReader struct {
+	chunks []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
 func TestMainHelper(t *testing.T) {
 	if os.Getenv("PTYX_EVENT_HELPER") != "1" {
 		return
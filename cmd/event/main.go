@@ -1,8 +1,6 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -10,8 +8,10 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/safedep/ptyx"
+	"github.com/safedep/ptyx/vtparse"
 )
 
 var (
@@ -27,6 +27,9 @@ const (
 	EventANSI      EventType = "ANSI"
 	EventControl   EventType = "CONTROL"
 	EventUnhandled EventType = "UNHANDLED"
+	EventESC       EventType = "ESC"
+	EventOSC       EventType = "OSC"
+	EventDCS       EventType = "DCS"
 )
 
 type Event struct {
@@ -84,53 +87,62 @@ func main() {
 }
 
 func processStream(w io.Writer, r io.Reader) {
-	reader := bufio.NewReader(r)
-	var textBuffer bytes.Buffer
+	var text strings.Builder
+	flushText := func() {
+		if text.Len() > 0 {
+			fmt.Fprintln(w, Event{Type: EventText, Payload: text.String()})
+			text.Reset()
+		}
+	}
 
+	// pending holds the bytes of a sequence the parser has begun but not
+	// finished, so one cut off by the end of the stream is still reported.
+	var pending []byte
+	p := vtparse.New(func(e vtparse.Event) {
+		pending = pending[:0]
+		var typ EventType
+		switch e := e.(type) {
+		case vtparse.Print:
+			text.WriteString(e.Text)
+			return
+		case vtparse.Execute:
+			if e.Code != '\r' && e.Code != '\n' {
+				text.WriteByte(e.Code)
+				return
+			}
+			typ = EventControl
+		case vtparse.CSI:
+			typ = EventANSI
+		case vtparse.ESC, vtparse.SingleShift:
+			typ = EventESC
+		case vtparse.OSC:
+			typ = EventOSC
+		case vtparse.DCS:
+			typ = EventDCS
+		}
+		flushText()
+		fmt.Fprintln(w, Event{Type: typ, Payload: e.String()})
+	})
+
+	buf := make([]byte, 32*1024)
 	for {
-		r, _, err := reader.ReadRune()
+		n, err := r.Read(buf)
+		for i := range n {
+			pending = append(pending, buf[i])
+			_, _ = p.Write(buf[i : i+1])
+		}
 		if err != nil {
-			if err == io.EOF && textBuffer.Len() > 0 {
-				fmt.Fprintln(w, Event{Type: EventText, Payload: textBuffer.String()})
-			}
 			break
 		}
-
-		switch r {
-		case '\x1b':
-			if textBuffer.Len() > 0 {
-				fmt.Fprintln(w, Event{Type: EventText, Payload: textBuffer.String()})
-				textBuffer.Reset()
-			}
-
-			next, _, err := reader.ReadRune()
-			if err != nil {
-				break
-			}
-			if next == '[' {
-				var csiSequence bytes.Buffer
-				for {
-					seqRune, _, seqErr := reader.ReadRune()
-					if seqErr != nil {
-						break
-					}
-					csiSequence.WriteRune(seqRune)
-					if seqRune >= 0x40 && seqRune <= 0x7E {
-						break
-					}
-				}
-				fmt.Fprintln(w, Event{Type: EventANSI, Payload: csiSequence.String()})
-			} else {
-				fmt.Fprintln(w, Event{Type: EventUnhandled, Payload: string(next)})
-			}
-		case '\r', '\n':
-			if textBuffer.Len() > 0 {
-				fmt.Fprintln(w, Event{Type: EventText, Payload: textBuffer.String()})
-				textBuffer.Reset()
-			}
-			fmt.Fprintln(w, Event{Type: EventControl, Payload: string(r)})
-		default:
-			textBuffer.WriteRune(r)
+	}
+	if len(pending) > 1 && pending[0] == 0x1b {
+		flushText()
+		if pending[1] == '[' {
+			fmt.Fprintln(w, Event{Type: EventANSI, Payload: string(pending[2:])})
+		} else {
+			fmt.Fprintln(w, Event{Type: EventUnhandled, Payload: string(pending[1:])})
 		}
 	}
+	p.Flush()
+	flushText()
 }
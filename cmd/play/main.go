@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/safedep/ptyx"
+	"github.com/safedep/ptyx/asciicast"
+)
+
+var (
+	parsePlayOptsFunc = ParsePlayOpts
+	newConsoleFunc    = ptyx.NewConsole
+	playFunc          = asciicast.Play
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(argv []string) error {
+	opts, err := parsePlayOptsFunc(argv)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(opts.File)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	c, err := newConsoleFunc()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+	c.EnableVT()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return playFunc(ctx, c, f, opts.Play)
+}
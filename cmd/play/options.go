@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/safedep/ptyx/asciicast"
+)
+
+type Options struct {
+	File string
+	Play asciicast.PlayOptions
+}
+
+func ParsePlayOpts(argv []string) (Options, error) {
+	fs := flag.NewFlagSet("play", flag.ContinueOnError)
+	var speed float64
+	var idle time.Duration
+	var resize bool
+	fs.Float64Var(&speed, "speed", 1, "")
+	fs.DurationVar(&idle, "idle", 0, "")
+	fs.BoolVar(&resize, "resize", true, "")
+	fs.SetOutput(io.Discard)
+
+	if err := fs.Parse(argv); err != nil {
+		return Options{}, err
+	}
+	if speed <= 0 {
+		return Options{}, fmt.Errorf("speed must be positive")
+	}
+	args := fs.Args()
+	if len(args) != 1 {
+		return Options{}, fmt.Errorf("usage: play [-speed N] [-idle D] [-resize=false] FILE")
+	}
+	return Options{
+		File: args[0],
+		Play: asciicast.PlayOptions{Speed: speed, IdleTimeLimit: idle, Controls: true, Resize: resize},
+	}, nil
+}
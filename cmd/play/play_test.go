@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/safedep/ptyx"
+	"github.com/safedep/ptyx/asciicast"
+	"github.com/safedep/ptyx/testptyx"
+)
+
+func TestParsePlayOpts(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    Options
+		wantErr bool
+	}{
+		{
+			name: "File only",
+			args: []string{"demo.cast"},
+			want: Options{File: "demo.cast", Play: asciicast.PlayOptions{Speed: 1, Controls: true, Resize: true}},
+		},
+		{
+			name: "All flags",
+			args: []string{"-speed", "2.5", "-idle", "1s", "-resize=false", "demo.cast"},
+			want: Options{File: "demo.cast", Play: asciicast.PlayOptions{Speed: 2.5, IdleTimeLimit: time.Second, Controls: true}},
+		},
+		{name: "No file", args: []string{}, wantErr: true},
+		{name: "Too many files", args: []string{"a.cast", "b.cast"}, wantErr: true},
+		{name: "Bad speed", args: []string{"-speed", "0", "a.cast"}, wantErr: true},
+		{name: "Unknown flag", args: []string{"-loop", "a.cast"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePlayOpts(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePlayOpts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePlayOpts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func writeCast(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "demo.cast")
+	data := "{\"version\":2,\"width\":80,\"height\":24}\n[0.001,\"o\",\"hello\"]\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	path := writeCast(t)
+	mc := testptyx.NewMockConsole("")
+	origConsole := newConsoleFunc
+	t.Cleanup(func() { newConsoleFunc = origConsole })
+	newConsoleFunc = func() (ptyx.Console, error) { return mc, nil }
+
+	if err := run([]string{path}); err != nil {
+		t.Fatalf("run() failed: %v", err)
+	}
+	if got := mc.OutBuffer.String(); got != "hello" {
+		t.Errorf("output = %q, want %q", got, "hello")
+	}
+
+	if err := run([]string{}); err == nil {
+		t.Error("run() should fail without a file")
+	}
+	if err := run([]string{filepath.Join(t.TempDir(), "missing.cast")}); err == nil {
+		t.Error("run() should fail for a missing file")
+	}
+
+	newConsoleFunc = func() (ptyx.Console, error) { return nil, errors.New("no console") }
+	if err := run([]string{path}); err == nil || !strings.Contains(err.Error(), "no console") {
+		t.Errorf("run() = %v, want console error", err)
+	}
+}
+
+func TestPlay_HelperProcess(t *testing.T) {
+	if os.Getenv("PTYX_PLAY_CMD_HELPER") != "1" {
+		return
+	}
+	newConsoleFunc = func() (ptyx.Console, error) { return testptyx.NewMockConsole(""), nil }
+	os.Args = []string{"play", os.Getenv("CAST_FILE")}
+	main()
+	os.Exit(0)
+}
+
+func TestPlay_MainExecution(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestPlay_HelperProcess$")
+		cmd.Env = append(os.Environ(), "PTYX_PLAY_CMD_HELPER=1", "CAST_FILE="+writeCast(t))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("process should have succeeded, but failed: %v\noutput: %s", err, output)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestPlay_HelperProcess$")
+		cmd.Env = append(os.Environ(), "PTYX_PLAY_CMD_HELPER=1", "CAST_FILE=")
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Fatal("process should have failed, but it succeeded")
+		}
+		if !strings.Contains(string(output), "Error:") {
+			t.Errorf("expected error message, got: %s", output)
+		}
+	})
+}
@@ -96,6 +96,9 @@ func handleWaitResult(spawnCtx context.Context, waitErr error) (int, string) {
 		if errors.As(waitErr, &exitErr) {
 			fmt.Fprintf(&b, "Exit code: %d\n", exitErr.ExitCode)
 			b.WriteString(checkSignal(waitErr))
+			if exitErr.KilledBy != ptyx.KilledByNone {
+				fmt.Fprintf(&b, "Stopped by ptyx: %s\n", exitErr.KilledBy)
+			}
 		}
 		if spawnCtx.Err() != nil {
 			b.WriteString("[DEMO] Process was interrupted.\n")
@@ -107,3 +110,14 @@ func handleWaitResult(spawnCtx context.Context, waitErr error) (int, string) {
 	b.WriteString("Process exited successfully with code 0.\n")
 	return 0, b.String()
 }
+
+func checkSignal(err error) string {
+	var exitErr *ptyx.ExitError
+	if errors.As(err, &exitErr) && exitErr.Signaled {
+		if exitErr.CoreDumped {
+			return fmt.Sprintf("Terminated by signal: %s (core dumped)\n", exitErr.Signal)
+		}
+		return fmt.Sprintf("Terminated by signal: %s\n", exitErr.Signal)
+	}
+	return ""
+}
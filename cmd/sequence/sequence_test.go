@@ -63,15 +63,32 @@ func (m *mockSequenceSession) PtyWriter() io.Writer {
 }
 func (m *mockSequenceSession) Resize(cols, rows int) error { return nil }
 func (m *mockSequenceSession) Wait() error {
+	<-m.Done()
+	return m.waitErr
+}
+func (m *mockSequenceSession) Done() <-chan struct{} {
 	if m.eofChan != nil {
-		<-m.eofChan
+		return m.eofChan
 	}
-	return m.waitErr
+	ch := make(chan struct{})
+	close(ch)
+	return ch
 }
-func (m *mockSequenceSession) Kill() error       { return nil }
-func (m *mockSequenceSession) Close() error      { return nil }
-func (m *mockSequenceSession) Pid() int          { return 1234 }
-func (m *mockSequenceSession) CloseStdin() error { return nil }
+func (m *mockSequenceSession) WaitContext(context.Context) error  { return m.Wait() }
+func (m *mockSequenceSession) ExitStatus() (int, bool)            { return 0, true }
+func (m *mockSequenceSession) State() ptyx.SessionState           { return ptyx.StateExited }
+func (m *mockSequenceSession) Result() (ptyx.Result, bool)        { return ptyx.Result{}, true }
+func (m *mockSequenceSession) Kill() error                        { return nil }
+func (m *mockSequenceSession) Close() error                       { return nil }
+func (m *mockSequenceSession) Pid() int                           { return 1234 }
+func (m *mockSequenceSession) CloseStdin() error                  { return nil }
+func (m *mockSequenceSession) Signal(ptyx.Signal) error           { return nil }
+func (m *mockSequenceSession) SignalForeground(ptyx.Signal) error { return nil }
+func (m *mockSequenceSession) Interrupt(ptyx.InterruptMode) error { return nil }
+func (m *mockSequenceSession) GetAttr() (ptyx.Termios, error)     { return ptyx.DefaultTermios(), nil }
+func (m *mockSequenceSession) SetAttr(ptyx.Termios) error         { return nil }
+func (m *mockSequenceSession) EchoEnabled() bool                  { return true }
+func (m *mockSequenceSession) OnEchoChange() <-chan bool          { return nil }
 
 func TestSequenceHelperProcess(t *testing.T) {
 	if os.Getenv("GO_TEST_SEQUENCE") == "1" {
@@ -359,6 +376,33 @@ func TestHandleWaitResult(t *testing.T) {
 	})
 }
 
+func TestCheckSignal(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Not an ExitError", errors.New("boom"), ""},
+		{"Exited normally", &ptyx.ExitError{ExitCode: 2}, ""},
+		{"Signaled", &ptyx.ExitError{ExitCode: -1, Signaled: true, Signal: ptyx.SIGINT}, "Terminated by signal: SIGINT\n"},
+		{"Core dump", &ptyx.ExitError{ExitCode: -1, Signaled: true, Signal: ptyx.SIGQUIT, CoreDumped: true}, "Terminated by signal: SIGQUIT (core dumped)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkSignal(tt.err); got != tt.want {
+				t.Errorf("checkSignal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleWaitResult_KilledBy(t *testing.T) {
+	_, output := handleWaitResult(context.Background(), &ptyx.ExitError{ExitCode: -1, KilledBy: ptyx.KilledByTimeout})
+	if !strings.Contains(output, "Stopped by ptyx: timeout") {
+		t.Errorf("Expected output to name the kill cause. Got:\n%s", output)
+	}
+}
+
 func TestRunCommandSequence_Errors(t *testing.T) {
 	t.Run("Write to PTY fails", func(t *testing.T) {
 		expectedErr := errors.New("write failed")
@@ -1,7 +0,0 @@
-//go:build windows
-
-package main
-
-func checkSignal(err error) string {
-	return ""
-}
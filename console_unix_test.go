@@ -10,7 +10,7 @@ import (
 )
 
 func newPlatformTestConsole(t *testing.T) (Console, func()) {
-	master, slave, err := openPTY()
+	master, slave, err := openPTY(defaultPtmx)
 	if err != nil {
 		t.Fatalf("failed to open pty: %v", err)
 	}
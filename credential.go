@@ -0,0 +1,13 @@
+package ptyx
+
+// Credential names the user a session runs as, which requires root. With
+// User set the uid, gid and groups come from the account database;
+// otherwise UID and GID are used as given and a nil Groups means the
+// account's groups, if the uid has one. Spawn returns ErrUnsupported on
+// Windows.
+type Credential struct {
+	User   string
+	UID    uint32
+	GID    uint32
+	Groups []uint32
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// pinTTY returns a path that reaches the slave's own inode until release:
+// the /proc link of an O_PATH descriptor, which unlike a dup does not keep
+// the terminal open and so does not hold off the master's end of file.
+// Without /proc it falls back to the slave's name.
+func pinTTY(slave *os.File) (string, func()) {
+	fd, err := unix.Open("/proc/self/fd/"+strconv.Itoa(int(slave.Fd())), unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return slave.Name(), func() {}
+	}
+	return "/proc/self/fd/" + strconv.Itoa(fd), func() { _ = unix.Close(fd) }
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestPinTTY(t *testing.T) {
+	p, err := OpenPTY()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	path, release := pinTTY(p.Slave())
+	defer release()
+	want, _ := p.Slave().Stat()
+	if got, err := os.Stat(path); err != nil || !os.SameFile(got, want) || path == p.Name() {
+		t.Fatalf("pinTTY() = %q, want a /proc path to the slave itself", path)
+	}
+
+	// The pinned reference must not keep the terminal open.
+	_ = p.Slave().Close()
+	if _, err := p.Master().Read(make([]byte, 1)); !errors.Is(err, syscall.EIO) {
+		t.Errorf("Read() on the master = %v, want EIO with no slave open", err)
+	}
+}
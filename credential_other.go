@@ -0,0 +1,9 @@
+//go:build unix && !linux
+
+package ptyx
+
+import "os"
+
+// pinTTY falls back to the slave's name; chownTTY checks it still names the
+// same inode before restoring.
+func pinTTY(slave *os.File) (string, func()) { return slave.Name(), func() {} }
@@ -0,0 +1,146 @@
+//go:build unix
+
+package ptyx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var passwdPath = "/etc/passwd"
+
+// ttyGroup owns the slave handed to another user, as login(1) does, so
+// write(1) can reach the terminal; the user's own group is used if absent.
+var ttyGroup = "tty"
+
+type account struct {
+	cred  syscall.Credential
+	found bool
+	name  string
+	home  string
+	shell string
+}
+
+func lookupAccount(c Credential) (*account, error) {
+	var u *user.User
+	var err error
+	if c.User != "" {
+		u, err = user.Lookup(c.User)
+	} else {
+		u, err = user.LookupId(strconv.FormatUint(uint64(c.UID), 10))
+	}
+	a := &account{cred: syscall.Credential{Uid: c.UID, Gid: c.GID, Groups: c.Groups}}
+	var unknown user.UnknownUserIdError
+	switch {
+	case c.User == "" && errors.As(err, &unknown):
+		return a, nil
+	case err != nil:
+		return nil, fmt.Errorf("ptyx: credential: %w", err)
+	}
+
+	a.found, a.name, a.home, a.shell = true, u.Username, u.HomeDir, loginShell(u.Username)
+	if c.User != "" {
+		uid, err1 := strconv.ParseUint(u.Uid, 10, 32)
+		gid, err2 := strconv.ParseUint(u.Gid, 10, 32)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, fmt.Errorf("ptyx: credential: %s: %w", c.User, err)
+		}
+		a.cred.Uid, a.cred.Gid, a.cred.Groups = uint32(uid), uint32(gid), nil
+	}
+	if a.cred.Groups == nil {
+		ids, _ := u.GroupIds()
+		a.cred.Groups = []uint32{}
+		for _, id := range ids {
+			if gid, err := strconv.ParseUint(id, 10, 32); err == nil {
+				a.cred.Groups = append(a.cred.Groups, uint32(gid))
+			}
+		}
+	}
+	return a, nil
+}
+
+// loginShell reads the shell field os/user does not expose.
+func loginShell(name string) string {
+	if f, err := os.Open(passwdPath); err == nil {
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			fields := strings.Split(sc.Text(), ":")
+			if len(fields) == 7 && fields[0] == name && fields[6] != "" {
+				return fields[6]
+			}
+		}
+	}
+	return "/bin/sh"
+}
+
+// environ sets the variables login(1) derives from the passwd entry.
+func (a *account) environ(env []string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	if !a.found {
+		return env
+	}
+	set := map[string]string{"HOME": a.home, "USER": a.name, "LOGNAME": a.name, "SHELL": a.shell}
+	out := make([]string, 0, len(env)+len(set))
+	for _, kv := range env {
+		k, _, _ := strings.Cut(kv, "=")
+		if _, ok := set[k]; !ok {
+			out = append(out, kv)
+		}
+	}
+	for _, k := range []string{"HOME", "USER", "LOGNAME", "SHELL"} {
+		out = append(out, k+"="+set[k])
+	}
+	return out
+}
+
+func loginArgv0(prog string) string { return "-" + filepath.Base(prog) }
+
+// chownTTY gives the slave to uid with mode 0620 and returns a function
+// restoring its previous owner and mode.
+func chownTTY(slave *os.File, cred *syscall.Credential) (func(), error) {
+	fi, err := slave.Stat()
+	if err != nil {
+		return nil, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, errors.New("no owner information")
+	}
+	gid := int(cred.Gid)
+	if g, err := user.LookupGroup(ttyGroup); err == nil {
+		if n, err := strconv.Atoi(g.Gid); err == nil {
+			gid = n
+		}
+	}
+	uid, oldGid, mode := int(st.Uid), int(st.Gid), fi.Mode().Perm()
+	// The mode goes first: if the chown then fails, the tty is still the
+	// old owner's and only its mode needs putting back.
+	if err := slave.Chmod(0o620); err != nil {
+		return nil, err
+	}
+	if err := slave.Chown(int(cred.Uid), gid); err != nil {
+		_ = slave.Chmod(mode)
+		return nil, err
+	}
+	path, release := pinTTY(slave)
+	return func() {
+		defer release()
+		// The slave's name may resolve in another devpts instance by now;
+		// never touch a tty other than the one that was handed out.
+		if cur, err := os.Stat(path); err != nil || !os.SameFile(cur, fi) {
+			return
+		}
+		_ = os.Chown(path, uid, oldGid)
+		_ = os.Chmod(path, mode)
+	}, nil
+}
@@ -0,0 +1,200 @@
+//go:build unix
+
+package ptyx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func requireRoot(t *testing.T) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("switching users needs root")
+	}
+}
+
+func TestLookupAccount(t *testing.T) {
+	passwd := filepath.Join(t.TempDir(), "passwd")
+	if err := os.WriteFile(passwd, []byte("root:x:0:0:root:/root:/bin/zsh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := passwdPath
+	passwdPath = passwd
+	defer func() { passwdPath = old }()
+
+	a, err := lookupAccount(Credential{User: "root"})
+	if err != nil {
+		t.Fatalf("lookupAccount() failed: %v", err)
+	}
+	if !a.found || a.cred.Uid != 0 || a.cred.Gid != 0 || a.name != "root" || a.shell != "/bin/zsh" || a.cred.Groups == nil {
+		t.Errorf("account = %+v", a)
+	}
+
+	a, err = lookupAccount(Credential{UID: 0, GID: 7, Groups: []uint32{8}})
+	if err != nil || a.cred.Gid != 7 || !slices.Equal(a.cred.Groups, []uint32{8}) || !a.found {
+		t.Errorf("lookupAccount(uid) = %+v, %v; want the given ids", a, err)
+	}
+
+	a, err = lookupAccount(Credential{UID: 4000123, GID: 4000123})
+	if err != nil || a.found || a.cred.Uid != 4000123 {
+		t.Errorf("lookupAccount(unknown uid) = %+v, %v; want the bare ids", a, err)
+	}
+	if env := a.environ([]string{"HOME=/x"}); !slices.Equal(env, []string{"HOME=/x"}) {
+		t.Errorf("environ() = %v, want it unchanged without a passwd entry", env)
+	}
+
+	if _, err := lookupAccount(Credential{User: "no-such-user-ptyx"}); err == nil {
+		t.Error("lookupAccount() of an unknown user should fail")
+	}
+}
+
+func TestAccount_Environ(t *testing.T) {
+	a := &account{found: true, name: "alice", home: "/home/alice", shell: "/bin/bash"}
+	got := a.environ([]string{"HOME=/root", "TERM=xterm", "USER=root", "LOGNAME=root", "SHELL=/bin/sh"})
+	want := []string{"TERM=xterm", "HOME=/home/alice", "USER=alice", "LOGNAME=alice", "SHELL=/bin/bash"}
+	if !slices.Equal(got, want) {
+		t.Errorf("environ() = %v, want %v", got, want)
+	}
+	if env := a.environ(nil); !slices.Contains(env, "USER=alice") {
+		t.Errorf("environ(nil) = %v, want the process environment with USER set", env)
+	}
+}
+
+func TestLoginShell_Default(t *testing.T) {
+	old := passwdPath
+	passwdPath = filepath.Join(t.TempDir(), "missing")
+	defer func() { passwdPath = old }()
+	if got := loginShell("root"); got != "/bin/sh" {
+		t.Errorf("loginShell() = %q, want /bin/sh", got)
+	}
+}
+
+func TestChownTTY(t *testing.T) {
+	requireRoot(t)
+	p, err := OpenPTY()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	before, err := os.Stat(p.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore, err := chownTTY(p.Slave(), &syscall.Credential{Uid: 65534, Gid: 65534})
+	if err != nil {
+		t.Fatalf("chownTTY() failed: %v", err)
+	}
+	fi, _ := os.Stat(p.Name())
+	if st := fi.Sys().(*syscall.Stat_t); st.Uid != 65534 || fi.Mode().Perm() != 0o620 {
+		t.Errorf("tty owner %d mode %v, want 65534 and 0620", st.Uid, fi.Mode().Perm())
+	}
+	restore()
+	fi, _ = os.Stat(p.Name())
+	if fi.Sys().(*syscall.Stat_t).Uid != before.Sys().(*syscall.Stat_t).Uid || fi.Mode() != before.Mode() {
+		t.Errorf("tty %v not restored to %v", fi.Mode(), before.Mode())
+	}
+}
+
+func runAs(t *testing.T, opts SpawnOpts, script string) string {
+	t.Helper()
+	opts.Prog, opts.Args = "sh", []string{"-c", script}
+	s, err := Spawn(context.Background(), opts)
+	if errors.Is(err, ErrUnsupported) {
+		t.Skipf("unsupported here: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Spawn() failed: %v", err)
+	}
+	defer s.Close()
+	out := make(chan string, 1)
+	go func() {
+		var b strings.Builder
+		buf := make([]byte, 4096)
+		for {
+			n, err := s.PtyReader().Read(buf)
+			b.Write(buf[:n])
+			if err != nil {
+				break
+			}
+		}
+		out <- strings.ReplaceAll(b.String(), "\r\n", "\n")
+	}()
+	if err := waitTimeout(t, s, 5*time.Second); err != nil {
+		t.Errorf("Wait() = %v", err)
+	}
+	_ = s.Close()
+	return <-out
+}
+
+func TestSpawn_Credential(t *testing.T) {
+	requireRoot(t)
+	const script = `id -u; id -g; echo "$HOME $USER $LOGNAME"; ls -ln "$(tty)" | cut -c1-10; ls -ln "$(tty)" | awk '{print $3}'`
+	want := "65534\n65534\n/nonexistent nobody nobody\ncrw--w----\n65534\n"
+	if _, err := os.Stat("/nonexistent"); err == nil {
+		t.Skip("nobody's home directory exists")
+	}
+
+	t.Run("Plain", func(t *testing.T) {
+		if out := runAs(t, SpawnOpts{Credential: &Credential{User: "nobody"}}, script); out != want {
+			t.Errorf("output = %q, want %q", out, want)
+		}
+	})
+	if runtime.GOOS != "linux" {
+		return
+	}
+	t.Run("Sandbox", func(t *testing.T) {
+		opts := SpawnOpts{Credential: &Credential{User: "nobody"}, Sandbox: &Sandbox{NoNewPrivs: true}}
+		if out := runAs(t, opts, script); out != want {
+			t.Errorf("output = %q, want %q", out, want)
+		}
+	})
+	t.Run("PID", func(t *testing.T) {
+		opts := SpawnOpts{Credential: &Credential{User: "nobody"}, Isolation: &Isolation{PID: true}}
+		if out := runAs(t, opts, script); out != want {
+			t.Errorf("output = %q, want %q", out, want)
+		}
+	})
+	t.Run("PIDSandbox", func(t *testing.T) {
+		opts := SpawnOpts{Credential: &Credential{User: "nobody"}, Isolation: &Isolation{PID: true}, Sandbox: &Sandbox{}}
+		if out := runAs(t, opts, script); out != want {
+			t.Errorf("output = %q, want %q", out, want)
+		}
+	})
+}
+
+func TestSpawn_LoginShell(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads argv[0] from /proc")
+	}
+	const script = `tr '\0' '\n' < /proc/$$/cmdline | head -1`
+	if out := runAs(t, SpawnOpts{LoginShell: true}, script); out != "-sh\n" {
+		t.Errorf("argv[0] = %q, want -sh", out)
+	}
+	if out := runAs(t, SpawnOpts{LoginShell: true, Sandbox: &Sandbox{}}, script); out != "-sh\n" {
+		t.Errorf("argv[0] under the init = %q, want -sh", out)
+	}
+}
+
+func TestSpawn_CredentialErrors(t *testing.T) {
+	_, err := Spawn(context.Background(), SpawnOpts{Prog: "true", Credential: &Credential{User: "no-such-user-ptyx"}})
+	if err == nil || !strings.Contains(err.Error(), "credential") {
+		t.Errorf("Spawn(unknown user) = %v, want a credential error", err)
+	}
+	if runtime.GOOS != "linux" {
+		return
+	}
+	_, err = Spawn(context.Background(), SpawnOpts{Prog: "true", Credential: &Credential{UID: 0}, Isolation: &Isolation{User: true}})
+	var isoErr *IsolationError
+	if !errors.As(err, &isoErr) || isoErr.Op != "credential" {
+		t.Errorf("Spawn(Credential, userns) = %v, want a credential IsolationError", err)
+	}
+}
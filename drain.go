@@ -0,0 +1,106 @@
+package ptyx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// drainTimeout bounds how long Wait keeps draining output after the child
+// exits, as a background job may hold the terminal open indefinitely.
+var drainTimeout = 2 * time.Second
+
+// drainBufferMax caps the output an outputPump holds for a reader that has
+// fallen behind. Once it is reached the pump stops reading the pty, so the
+// child blocks on its next write as it would with no reader at all.
+var drainBufferMax = 1 << 20
+
+// outputPump copies a pty's output into memory as fast as it arrives, so
+// that it outlives the master being closed. Reads are served from the
+// buffer and return the source's error once it is empty.
+type outputPump struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     bytes.Buffer
+	max     int
+	err     error
+	stopped bool
+	done    chan struct{}
+}
+
+func newOutputPump(src io.Reader) *outputPump {
+	p := &outputPump{max: drainBufferMax, done: make(chan struct{})}
+	p.cond = sync.NewCond(&p.mu)
+	go p.run(src)
+	return p
+}
+
+func (p *outputPump) run(src io.Reader) {
+	chunk := make([]byte, 32*1024)
+	for {
+		p.mu.Lock()
+		for p.buf.Len() >= p.max && !p.stopped {
+			p.cond.Wait()
+		}
+		p.mu.Unlock()
+		n, err := src.Read(chunk)
+		p.mu.Lock()
+		p.buf.Write(chunk[:n])
+		if err != nil {
+			// Closing the master ends the output as far as the reader is
+			// concerned.
+			if errors.Is(err, os.ErrClosed) {
+				err = io.EOF
+			}
+			p.err = err
+			close(p.done)
+		}
+		p.cond.Broadcast()
+		p.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *outputPump) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.buf.Len() == 0 && p.err == nil {
+		p.cond.Wait()
+	}
+	if p.buf.Len() > 0 {
+		n, err := p.buf.Read(b)
+		p.cond.Broadcast()
+		return n, err
+	}
+	return 0, p.err
+}
+
+// stop lets a pump held back by a full buffer read again, so it sees the
+// master being closed and ends.
+func (p *outputPump) stop() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.stopped = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+// drain waits until the source is exhausted or drainTimeout has passed.
+func (p *outputPump) drain() {
+	if p == nil {
+		return
+	}
+	t := time.NewTimer(drainTimeout)
+	defer t.Stop()
+	select {
+	case <-p.done:
+	case <-t.C:
+	}
+}
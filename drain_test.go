@@ -0,0 +1,104 @@
+package ptyx
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type errAfterReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errAfterReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err == io.EOF {
+		err = e.err
+	}
+	return n, err
+}
+
+func TestOutputPump_BuffersUntilRead(t *testing.T) {
+	p := newOutputPump(strings.NewReader(strings.Repeat("x", 100000)))
+	p.drain()
+	select {
+	case <-p.done:
+	default:
+		t.Fatal("drain() returned before the source was exhausted")
+	}
+	b, err := io.ReadAll(p)
+	if err != nil || len(b) != 100000 {
+		t.Errorf("ReadAll() = %d bytes, %v; want all 100000", len(b), err)
+	}
+	if n, err := p.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("Read() after the end = %d, %v; want io.EOF", n, err)
+	}
+}
+
+func TestOutputPump_Errors(t *testing.T) {
+	p := newOutputPump(&errAfterReader{strings.NewReader("tail"), os.ErrClosed})
+	if b, err := io.ReadAll(p); string(b) != "tail" || err != nil {
+		t.Errorf("ReadAll() = %q, %v; want the data and a clean end once the master is closed", b, err)
+	}
+
+	boom := errors.New("boom")
+	p = newOutputPump(&errAfterReader{strings.NewReader(""), boom})
+	if _, err := p.Read(make([]byte, 4)); err != boom {
+		t.Errorf("Read() = %v, want the source error", err)
+	}
+}
+
+func TestOutputPump_DrainTimeout(t *testing.T) {
+	old := drainTimeout
+	drainTimeout = 20 * time.Millisecond
+	defer func() { drainTimeout = old }()
+
+	r, w := io.Pipe()
+	defer w.Close()
+	p := newOutputPump(r)
+	start := time.Now()
+	p.drain()
+	if time.Since(start) > time.Second {
+		t.Error("drain() did not give up on a source that never ends")
+	}
+	(*outputPump)(nil).drain()
+}
+
+func TestOutputPump_BufferLimit(t *testing.T) {
+	old := drainBufferMax
+	drainBufferMax = 64 * 1024
+	defer func() { drainBufferMax = old }()
+
+	r, w := io.Pipe()
+	p := newOutputPump(r)
+	go func() {
+		_, _ = w.Write([]byte(strings.Repeat("x", 1<<20)))
+		_ = w.Close()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	p.mu.Lock()
+	held := p.buf.Len()
+	p.mu.Unlock()
+	if held > drainBufferMax+32*1024 {
+		t.Errorf("pump holds %d bytes, want at most about %d", held, drainBufferMax)
+	}
+	if b, err := io.ReadAll(p); err != nil || len(b) != 1<<20 {
+		t.Errorf("ReadAll() = %d bytes, %v; want all of it once read", len(b), err)
+	}
+
+	r, w = io.Pipe()
+	p = newOutputPump(r)
+	go func() { _, _ = w.Write([]byte(strings.Repeat("x", 128*1024))) }()
+	time.Sleep(50 * time.Millisecond)
+	p.stop()
+	_ = r.CloseWithError(os.ErrClosed)
+	select {
+	case <-p.done:
+	case <-time.After(time.Second):
+		t.Fatal("stop() did not let a full pump see the source end")
+	}
+}
@@ -1,21 +1,107 @@
 package ptyx
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 )
 
 var ErrMuxAlreadyStarted = errors.New("ptyx: mux already started")
 
+var ErrUnsupported = errors.New("ptyx: operation not supported")
+
+var defaultKillDelay = 5 * time.Second
+
+// KillCause records why ptyx itself stopped a process.
+type KillCause int
+
+const (
+	KilledByNone KillCause = iota
+	KilledByContext
+	KilledByTimeout
+	KilledByKill
+	KilledByClose
+)
+
+func (c KillCause) String() string {
+	switch c {
+	case KilledByContext:
+		return "context"
+	case KilledByTimeout:
+		return "timeout"
+	case KilledByKill:
+		return "kill"
+	case KilledByClose:
+		return "close"
+	}
+	return "none"
+}
+
+// contextKillCause tells a timeout from a cancellation, also when the
+// deadline was passed on as the cause of a cancellation, as Run does.
+func contextKillCause(ctx context.Context) KillCause {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+		return KilledByTimeout
+	}
+	return KilledByContext
+}
+
 type ExitError struct {
 	ExitCode int
-	waitStatus any
+	// Signaled reports that the process was terminated by Signal, with a
+	// core dump if CoreDumped is set. Windows never reports signals.
+	Signaled   bool
+	Signal     Signal
+	CoreDumped bool
+	// KilledBy is set when ptyx stopped the process: its spawn context was
+	// cancelled or timed out, or Kill or Close was called while it ran.
+	KilledBy KillCause
+	// ForceKilled reports that the process ignored SpawnOpts.CancelSignal
+	// and was killed once SpawnOpts.KillDelay elapsed, or when Kill was
+	// called again before then.
+	ForceKilled bool
+	waitStatus  any
 }
 
 func (e *ExitError) Error() string {
-	return fmt.Sprintf("process exited with status %d", e.ExitCode)
+	msg := fmt.Sprintf("process exited with status %d", e.ExitCode)
+	if e.Signaled {
+		sig := e.Signal.String()
+		if _, ok := signalNames[e.Signal]; ok {
+			sig = "signal " + sig
+		}
+		msg = "process terminated by " + sig
+		if e.CoreDumped {
+			msg += " (core dumped)"
+		}
+	}
+	if e.ForceKilled {
+		msg += " (killed after grace period)"
+	}
+	return msg
 }
 
 func (e *ExitError) Sys() any {
 	return e.waitStatus
 }
+
+// ContextError is returned by Run and RunInteractive when their context ends
+// before the process exits. It unwraps to the context's error only, so
+// errors.As does not mistake a cancellation for an exit; Exit holds the
+// stopped process's status, or nil if it had none.
+type ContextError struct {
+	Err  error
+	Exit *ExitError
+}
+
+func (e *ContextError) Error() string {
+	if e.Exit == nil {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + e.Exit.Error()
+}
+
+func (e *ContextError) Unwrap() error {
+	return e.Err
+}
@@ -1,6 +1,7 @@
 package ptyx
 
 import (
+	"context"
 	"testing"
 )
 
@@ -22,3 +23,48 @@ func TestExitError_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestExitError_ForceKilled(t *testing.T) {
+	e := &ExitError{ExitCode: -1, ForceKilled: true}
+	if want := "process exited with status -1 (killed after grace period)"; e.Error() != want {
+		t.Errorf("ExitError.Error() = %v, want %v", e.Error(), want)
+	}
+}
+
+func TestExitError_Signaled(t *testing.T) {
+	tests := []struct {
+		err  *ExitError
+		want string
+	}{
+		{&ExitError{ExitCode: -1, Signaled: true, Signal: SIGTERM}, "process terminated by signal SIGTERM"},
+		{&ExitError{ExitCode: -1, Signaled: true, Signal: SIGQUIT, CoreDumped: true}, "process terminated by signal SIGQUIT (core dumped)"},
+		{&ExitError{ExitCode: -1, Signaled: true, Signal: SIGKILL, ForceKilled: true}, "process terminated by signal SIGKILL (killed after grace period)"},
+		{&ExitError{ExitCode: -1, Signaled: true, Signal: 40}, "process terminated by signal 40"},
+		{&ExitError{ExitCode: -1, Signaled: true, Signal: nativeSignalBase + 33}, "process terminated by native signal 33"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("ExitError.Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestKillCause(t *testing.T) {
+	for c, want := range map[KillCause]string{
+		KilledByNone: "none", KilledByContext: "context", KilledByTimeout: "timeout",
+		KilledByKill: "kill", KilledByClose: "close",
+	} {
+		if c.String() != want {
+			t.Errorf("KillCause(%d).String() = %q, want %q", c, c.String(), want)
+		}
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	passedOn, cancelCause := context.WithCancelCause(context.Background())
+	cancelCause(context.DeadlineExceeded)
+	if contextKillCause(cancelled) != KilledByContext || contextKillCause(expired) != KilledByTimeout || contextKillCause(passedOn) != KilledByTimeout {
+		t.Error("contextKillCause() mismatch")
+	}
+}
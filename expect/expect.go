@@ -0,0 +1,267 @@
+// Package expect drives an interactive program running in a ptyx.Session by
+// waiting for its output to match literals, regular expressions, EOF or a
+// timeout, and sending input back through the PTY.
+package expect
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/safedep/ptyx"
+)
+
+var (
+	// ErrNoMatchers is returned by Expect when called without matchers.
+	ErrNoMatchers = errors.New("expect: no matchers given")
+	// ErrTimeout is returned with the Result of a Timeout matcher, so a
+	// timeout is not mistaken for a match.
+	ErrTimeout = errors.New("expect: timed out")
+)
+
+// Matcher is something Expect waits for in the session's output. Use
+// String, Regexp, EOF or Timeout to make one.
+type Matcher interface {
+	match(buf []byte, eof bool) (start, end int, groups []string, ok bool)
+}
+
+type stringMatcher struct{ s []byte }
+
+// String matches the literal s.
+func String(s string) Matcher { return &stringMatcher{s: []byte(s)} }
+
+func (m *stringMatcher) match(buf []byte, _ bool) (int, int, []string, bool) {
+	i := bytes.Index(buf, m.s)
+	if i < 0 {
+		return 0, 0, nil, false
+	}
+	return i, i + len(m.s), nil, true
+}
+
+type regexpMatcher struct{ re *regexp.Regexp }
+
+// Regexp matches re; its submatches are returned in Result.Groups.
+func Regexp(re *regexp.Regexp) Matcher { return &regexpMatcher{re: re} }
+
+func (m *regexpMatcher) match(buf []byte, _ bool) (int, int, []string, bool) {
+	loc := m.re.FindSubmatchIndex(buf)
+	if loc == nil {
+		return 0, 0, nil, false
+	}
+	groups := make([]string, len(loc)/2)
+	for i := range groups {
+		if loc[2*i] >= 0 {
+			groups[i] = string(buf[loc[2*i]:loc[2*i+1]])
+		}
+	}
+	return loc[0], loc[1], groups, true
+}
+
+type eofMatcher struct{}
+
+// EOF matches once the session's output has ended, with everything still
+// unmatched as Result.Before.
+func EOF() Matcher { return eofMatcher{} }
+
+func (eofMatcher) match(buf []byte, eof bool) (int, int, []string, bool) {
+	if !eof {
+		return 0, 0, nil, false
+	}
+	return len(buf), len(buf), nil, true
+}
+
+type timeoutMatcher struct{ d time.Duration }
+
+// Timeout fires when nothing else has matched within d of Expect being
+// called; Expect then returns ErrTimeout along with its Result.
+func Timeout(d time.Duration) Matcher { return timeoutMatcher{d: d} }
+
+func (timeoutMatcher) match([]byte, bool) (int, int, []string, bool) {
+	return 0, 0, nil, false
+}
+
+// Result describes what Expect matched. Index is the position of the
+// matcher that fired, Before the output preceding the match and Groups the
+// submatches of a Regexp. Dropped counts the bytes WithMaxBuffer discarded
+// from the front of Before; it is zero unless a limit was set.
+type Result struct {
+	Index   int
+	Before  string
+	Match   string
+	Groups  []string
+	Dropped int
+}
+
+// Option configures an Expecter in New.
+type Option func(*Expecter)
+
+// WithTee copies all output read from the session to w, matched or not.
+func WithTee(w io.Writer) Option { return func(e *Expecter) { e.tee = w } }
+
+// WithMaxBuffer caps the unmatched output kept between calls at n bytes,
+// dropping the oldest output beyond it and counting it in Result.Dropped.
+// By default, or with zero or less, everything is kept.
+func WithMaxBuffer(n int) Option { return func(e *Expecter) { e.max = n } }
+
+// Expecter reads a session's output in the background and matches it
+// against what Expect is given, keeping unmatched output for the next call.
+type Expecter struct {
+	s   ptyx.Session
+	tee io.Writer
+	max int
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	dropped int
+	eof     bool
+	readErr error
+	notify  chan struct{}
+}
+
+// New starts reading s's output. Nothing else should read PtyReader once
+// an Expecter owns it.
+func New(s ptyx.Session, opts ...Option) *Expecter {
+	e := &Expecter{s: s, notify: make(chan struct{})}
+	for _, o := range opts {
+		o(e)
+	}
+	go e.readLoop()
+	return e
+}
+
+func (e *Expecter) readLoop() {
+	p := make([]byte, 32*1024)
+	r := e.s.PtyReader()
+	for {
+		n, err := r.Read(p)
+		e.mu.Lock()
+		if n > 0 {
+			e.buf.Write(p[:n])
+			if e.max > 0 && e.buf.Len() > e.max {
+				n := e.buf.Len() - e.max
+				e.buf.Next(n)
+				e.dropped += n
+			}
+			if e.tee != nil {
+				_, _ = e.tee.Write(p[:n])
+			}
+		}
+		if err != nil {
+			e.eof = true
+			if !isEOF(err) {
+				e.readErr = err
+			}
+		}
+		close(e.notify)
+		e.notify = make(chan struct{})
+		e.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Expect waits until one of matchers matches the unmatched output and
+// consumes it up to the end of the match. The earliest match wins. If the
+// output ends first, it returns io.EOF or the read error; a Timeout
+// matcher's Result comes with ErrTimeout and consumes nothing.
+func (e *Expecter) Expect(ctx context.Context, matchers ...Matcher) (*Result, error) {
+	if len(matchers) == 0 {
+		return nil, ErrNoMatchers
+	}
+
+	timeoutIdx := -1
+	var timeout <-chan time.Time
+	for i, m := range matchers {
+		if tm, ok := m.(timeoutMatcher); ok && timeoutIdx < 0 {
+			timeoutIdx = i
+			t := time.NewTimer(tm.d)
+			defer t.Stop()
+			timeout = t.C
+		}
+	}
+
+	for {
+		e.mu.Lock()
+		if res, ok := e.scan(matchers); ok {
+			e.mu.Unlock()
+			return res, nil
+		}
+		if e.eof {
+			err := e.readErr
+			e.mu.Unlock()
+			if err == nil {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		notify := e.notify
+		e.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-timeout:
+			e.mu.Lock()
+			res := &Result{Index: timeoutIdx, Before: e.buf.String(), Dropped: e.dropped}
+			e.mu.Unlock()
+			return res, ErrTimeout
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (e *Expecter) scan(matchers []Matcher) (*Result, bool) {
+	data := e.buf.Bytes()
+	best := -1
+	var bestStart, bestEnd int
+	var bestGroups []string
+	for i, m := range matchers {
+		start, end, groups, ok := m.match(data, e.eof)
+		if ok && (best < 0 || start < bestStart) {
+			best, bestStart, bestEnd, bestGroups = i, start, end, groups
+		}
+	}
+	if best < 0 {
+		return nil, false
+	}
+	res := &Result{
+		Index:   best,
+		Before:  string(data[:bestStart]),
+		Match:   string(data[bestStart:bestEnd]),
+		Groups:  bestGroups,
+		Dropped: e.dropped,
+	}
+	e.buf.Next(bestEnd)
+	e.dropped = 0
+	return res, true
+}
+
+// Buffered returns the output read but not yet matched.
+func (e *Expecter) Buffered() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.buf.String()
+}
+
+// Send writes s to the session as typed input.
+func (e *Expecter) Send(s string) error {
+	_, err := io.WriteString(e.s.PtyWriter(), s)
+	return err
+}
+
+// SendLine sends s followed by a carriage return, as Enter does.
+func (e *Expecter) SendLine(s string) error {
+	return e.Send(s + "\r")
+}
+
+func isEOF(err error) bool {
+	var errno syscall.Errno
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, os.ErrClosed) || (errors.As(err, &errno) && errno == syscall.EIO)
+}
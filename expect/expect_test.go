@@ -0,0 +1,224 @@
+package expect
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/safedep/ptyx/testptyx"
+)
+
+func newPipeSession() (*testptyx.MockSession, *io.PipeWriter) {
+	r, w := io.Pipe()
+	s := testptyx.NewMockSession("")
+	s.PtyOutReader = r
+	return s, w
+}
+
+func TestExpect_String(t *testing.T) {
+	e := New(testptyx.NewMockSession("login: admin\r\nPassword: "))
+
+	res, err := e.Expect(context.Background(), String("Password: "))
+	if err != nil {
+		t.Fatalf("Expect() failed: %v", err)
+	}
+	if res.Index != 0 || res.Match != "Password: " || res.Before != "login: admin\r\n" {
+		t.Errorf("Expect() = %+v, want match %q before %q", res, "Password: ", "login: admin\r\n")
+	}
+}
+
+func TestExpect_Regexp(t *testing.T) {
+	e := New(testptyx.NewMockSession("version 1.24.3 ready"))
+
+	res, err := e.Expect(context.Background(), Regexp(regexp.MustCompile(`version (\d+)\.(\d+)`)))
+	if err != nil {
+		t.Fatalf("Expect() failed: %v", err)
+	}
+	if res.Match != "version 1.24" {
+		t.Errorf("Match = %q, want %q", res.Match, "version 1.24")
+	}
+	if len(res.Groups) != 3 || res.Groups[1] != "1" || res.Groups[2] != "24" {
+		t.Errorf("Groups = %q, want [version 1.24 1 24]", res.Groups)
+	}
+	if got := e.Buffered(); got != ".3 ready" {
+		t.Errorf("Buffered() = %q, want %q", got, ".3 ready")
+	}
+}
+
+func TestExpect_EarliestMatchWins(t *testing.T) {
+	e := New(testptyx.NewMockSession("yes or no?"))
+
+	res, err := e.Expect(context.Background(), String("no"), String("yes"))
+	if err != nil {
+		t.Fatalf("Expect() failed: %v", err)
+	}
+	if res.Index != 1 {
+		t.Errorf("Index = %d, want 1", res.Index)
+	}
+}
+
+func TestExpect_KeepsOutputBetweenCalls(t *testing.T) {
+	s, w := newPipeSession()
+	e := New(s)
+
+	go func() {
+		_, _ = io.WriteString(w, "first> ")
+		_, _ = io.WriteString(w, "second> ")
+		_ = w.Close()
+	}()
+
+	for _, want := range []string{"first> ", "second> "} {
+		res, err := e.Expect(context.Background(), String(want))
+		if err != nil {
+			t.Fatalf("Expect(%q) failed: %v", want, err)
+		}
+		if res.Match != want || res.Before != "" {
+			t.Errorf("Expect(%q) = %+v", want, res)
+		}
+	}
+}
+
+func TestExpect_SplitAcrossReads(t *testing.T) {
+	s, w := newPipeSession()
+	e := New(s)
+
+	go func() {
+		_, _ = io.WriteString(w, "What is your na")
+		time.Sleep(10 * time.Millisecond)
+		_, _ = io.WriteString(w, "me? ")
+	}()
+	defer w.Close()
+
+	if _, err := e.Expect(context.Background(), String("What is your name? ")); err != nil {
+		t.Fatalf("Expect() failed: %v", err)
+	}
+}
+
+func TestExpect_EOF(t *testing.T) {
+	t.Run("Matcher", func(t *testing.T) {
+		e := New(testptyx.NewMockSession("bye"))
+		res, err := e.Expect(context.Background(), String("never"), EOF())
+		if err != nil {
+			t.Fatalf("Expect() failed: %v", err)
+		}
+		if res.Index != 1 || res.Before != "bye" {
+			t.Errorf("Expect() = %+v, want EOF match with Before %q", res, "bye")
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		e := New(testptyx.NewMockSession("bye"))
+		_, err := e.Expect(context.Background(), String("never"))
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("Expect() error = %v, want io.EOF", err)
+		}
+	})
+
+	t.Run("ReadError", func(t *testing.T) {
+		s, w := newPipeSession()
+		readErr := errors.New("read failed")
+		_ = w.CloseWithError(readErr)
+		e := New(s)
+		_, err := e.Expect(context.Background(), String("never"))
+		if !errors.Is(err, readErr) {
+			t.Errorf("Expect() error = %v, want %v", err, readErr)
+		}
+	})
+}
+
+func TestExpect_Timeout(t *testing.T) {
+	s, w := newPipeSession()
+	defer w.Close()
+	e := New(s)
+
+	go func() { _, _ = io.WriteString(w, "partial") }()
+
+	res, err := e.Expect(context.Background(), String("never"), Timeout(50*time.Millisecond))
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Expect() error = %v, want ErrTimeout", err)
+	}
+	if res.Index != 1 || res.Before != "partial" {
+		t.Errorf("Expect() = %+v, want timeout match with Before %q", res, "partial")
+	}
+	if got := e.Buffered(); got != "partial" {
+		t.Errorf("Buffered() = %q, timeout should not consume output", got)
+	}
+}
+
+func TestExpect_MaxBuffer(t *testing.T) {
+	e := New(testptyx.NewMockSession("0123456789"), WithMaxBuffer(4))
+	res, err := e.Expect(context.Background(), String("0"), EOF())
+	if err != nil {
+		t.Fatalf("Expect() failed: %v", err)
+	}
+	if res.Index != 1 || res.Before != "6789" || res.Dropped != 6 {
+		t.Errorf("Expect() = %+v, want EOF with only the last 4 bytes kept and 6 dropped", res)
+	}
+}
+
+func TestExpect_UnboundedByDefault(t *testing.T) {
+	out := strings.Repeat("x", 2<<20)
+	e := New(testptyx.NewMockSession(out))
+	res, err := e.Expect(context.Background(), EOF())
+	if err != nil {
+		t.Fatalf("Expect() failed: %v", err)
+	}
+	if len(res.Before) != len(out) || res.Dropped != 0 {
+		t.Errorf("Expect() kept %d bytes and dropped %d, want all %d kept", len(res.Before), res.Dropped, len(out))
+	}
+}
+
+func TestExpect_ContextDone(t *testing.T) {
+	s, w := newPipeSession()
+	defer w.Close()
+	e := New(s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := e.Expect(ctx, String("never")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expect() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestExpect_NoMatchers(t *testing.T) {
+	e := New(testptyx.NewMockSession(""))
+	if _, err := e.Expect(context.Background()); !errors.Is(err, ErrNoMatchers) {
+		t.Errorf("Expect() error = %v, want ErrNoMatchers", err)
+	}
+}
+
+func TestExpect_Tee(t *testing.T) {
+	var tee bytes.Buffer
+	e := New(testptyx.NewMockSession("hello"), WithTee(&tee))
+	if _, err := e.Expect(context.Background(), EOF()); err != nil {
+		t.Fatalf("Expect() failed: %v", err)
+	}
+	if tee.String() != "hello" {
+		t.Errorf("tee = %q, want %q", tee.String(), "hello")
+	}
+}
+
+func TestSend(t *testing.T) {
+	s := testptyx.NewMockSession("")
+	e := New(s)
+
+	if err := e.Send("ab"); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if err := e.SendLine("cd"); err != nil {
+		t.Fatalf("SendLine() failed: %v", err)
+	}
+	if got := s.PtyInBuffer.String(); got != "abcd\r" {
+		t.Errorf("written = %q, want %q", got, "abcd\r")
+	}
+
+	s.ForceWriteError = errors.New("write failed")
+	if err := e.SendLine("x"); err == nil {
+		t.Error("SendLine() should fail when the PTY write fails")
+	}
+}
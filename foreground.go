@@ -0,0 +1,22 @@
+package ptyx
+
+import (
+	"slices"
+	"time"
+)
+
+var foregroundPollInterval = 100 * time.Millisecond
+
+// ForegroundProcess describes the leader of the process group in the
+// foreground of a session's terminal.
+type ForegroundProcess struct {
+	Pid  int
+	Name string
+	// Argv is empty once the process has exited and is a zombie.
+	Argv []string
+	Dir  string
+}
+
+func (p ForegroundProcess) equal(q ForegroundProcess) bool {
+	return p.Pid == q.Pid && p.Name == q.Name && p.Dir == q.Dir && slices.Equal(p.Argv, q.Argv)
+}
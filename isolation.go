@@ -0,0 +1,82 @@
+package ptyx
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+var ErrUserNamespacesDisabled = errors.New("ptyx: unprivileged user namespaces are disabled")
+
+// initEnv carries the init's configuration; the init removes it from the
+// environment before starting the program.
+const initEnv = "_PTYX_INIT"
+
+var initCalled atomic.Bool
+
+// Init must be called first thing in main by programs that spawn with
+// SpawnOpts.Isolation or SpawnOpts.Sandbox. Those re-execute the program as
+// a small init, in which Init sets up the child and never returns; in any
+// other process it returns at once. Test binaries call it from TestMain.
+func Init() {
+	initCalled.Store(true)
+	reexecInit()
+}
+
+// Isolation runs the child in fresh Linux namespaces. A small init re-exec'd
+// from the current binary, which has to call Init, sets them up before
+// starting the program, so the Session API is unchanged. Spawn returns
+// ErrUnsupported on other platforms.
+type Isolation struct {
+	// User creates a user namespace. Without mappings the caller's uid and
+	// gid become root inside it.
+	User        bool
+	UIDMappings []IDMap
+	GIDMappings []IDMap
+
+	// PID creates a pid namespace whose pid 1 is the init, which reaps
+	// orphans and forwards signals to the program.
+	PID bool
+
+	// Mount creates a mount namespace, implied by Root or Mounts. Mounts are
+	// bind mounted read-only unless Writable, creating missing targets, and
+	// so is every mount below them. With Root set they are made under Root,
+	// which is then bound read-only down to its Writable mounts and becomes
+	// the filesystem root.
+	Mount  bool
+	Root   string
+	Mounts []BindMount
+
+	// Hostname gives the child its own UTS namespace with this hostname.
+	Hostname string
+
+	// Network creates a network namespace with only a loopback device.
+	Network bool
+}
+
+// IDMap maps Size ids starting at HostID to ContainerID inside the user
+// namespace.
+type IDMap struct {
+	ContainerID int
+	HostID      int
+	Size        int
+}
+
+type BindMount struct {
+	Source   string
+	Target   string
+	Writable bool
+}
+
+// IsolationError reports a failure to set up SpawnOpts.Isolation. Op names
+// the failing step, such as "userns", "mount" or "sethostname".
+type IsolationError struct {
+	Op  string
+	Err error
+}
+
+func (e *IsolationError) Error() string { return "ptyx: isolation " + e.Op + ": " + e.Err.Error() }
+func (e *IsolationError) Unwrap() error { return e.Err }
+
+func (e *IsolationError) Is(target error) bool {
+	return target == ErrUserNamespacesDisabled && e.Op == "userns"
+}
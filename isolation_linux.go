@@ -0,0 +1,579 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// initReportFd is where the init finds the report pipe: the first of
+// cmd.ExtraFiles.
+var initReportFd = 3
+
+// reexecInit runs the init and exits without the exit hooks os.Exit runs,
+// such as a test binary's coverage output, which belong to the program the
+// init was re-executed from.
+func reexecInit() {
+	if cfg, ok := os.LookupEnv(initEnv); ok {
+		syscall.Exit(runInit(cfg))
+	}
+}
+
+type initConfig struct {
+	Prog       string
+	Args       []string
+	Argv0      string
+	Dir        string
+	Isolation  Isolation
+	Sandbox    *Sandbox
+	Credential *syscall.Credential
+}
+
+func (cfg *initConfig) argv() []string {
+	argv0 := cfg.Prog
+	if cfg.Argv0 != "" {
+		argv0 = cfg.Argv0
+	}
+	return append([]string{argv0}, cfg.Args...)
+}
+
+// initReport is written by the init on fd 3: once when the program is about
+// to start or setup failed, and with the program's wait status at exit.
+type initReport struct {
+	Ready   bool           `json:"ready,omitempty"`
+	Op      string         `json:"op,omitempty"`
+	Err     string         `json:"err,omitempty"`
+	Status  *int           `json:"status,omitempty"`
+	Sandbox *SandboxReport `json:"sandbox,omitempty"`
+}
+
+type initPipe struct {
+	f   *os.File
+	dec *json.Decoder
+}
+
+func (iso *Isolation) mountNS() bool { return iso.Mount || iso.Root != "" || len(iso.Mounts) > 0 }
+
+func (iso *Isolation) cloneflags() uintptr {
+	var flags uintptr
+	if iso.User {
+		flags |= syscall.CLONE_NEWUSER
+	}
+	if iso.PID {
+		flags |= syscall.CLONE_NEWPID
+	}
+	if iso.mountNS() {
+		flags |= syscall.CLONE_NEWNS
+	}
+	if iso.Hostname != "" {
+		flags |= syscall.CLONE_NEWUTS
+	}
+	if iso.Network {
+		flags |= syscall.CLONE_NEWNET
+	}
+	return flags
+}
+
+func idMaps(maps []IDMap, self int) []syscall.SysProcIDMap {
+	if len(maps) == 0 {
+		return []syscall.SysProcIDMap{{ContainerID: 0, HostID: self, Size: 1}}
+	}
+	out := make([]syscall.SysProcIDMap, len(maps))
+	for i, m := range maps {
+		out[i] = syscall.SysProcIDMap{ContainerID: m.ContainerID, HostID: m.HostID, Size: m.Size}
+	}
+	return out
+}
+
+func spawnIsolated(ctx context.Context, opts SpawnOpts, cred *syscall.Credential) (Session, error) {
+	var iso Isolation
+	if opts.Isolation != nil {
+		iso = *opts.Isolation
+	}
+	if !initCalled.Load() {
+		return nil, &IsolationError{Op: "init", Err: errors.New("ptyx.Init was not called at the start of main")}
+	}
+	if iso.User && cred != nil {
+		// The tty would be handed to a host id the namespace may not map.
+		return nil, &IsolationError{Op: "credential", Err: errors.New("not supported with a user namespace")}
+	}
+	ic := initConfig{Prog: opts.Prog, Args: opts.Args, Dir: opts.Dir, Isolation: iso, Sandbox: opts.Sandbox, Credential: cred}
+	if opts.LoginShell {
+		ic.Argv0 = loginArgv0(opts.Prog)
+	}
+	cfg, err := json.Marshal(ic)
+	if err != nil {
+		return nil, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	env := opts.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	attr := &syscall.SysProcAttr{Cloneflags: iso.cloneflags()}
+	if iso.User {
+		attr.UidMappings = idMaps(iso.UIDMappings, os.Geteuid())
+		attr.GidMappings = idMaps(iso.GIDMappings, os.Getegid())
+	}
+	cmd := &exec.Cmd{
+		Path:        "/proc/self/exe",
+		Args:        []string{"ptyx-init"},
+		Env:         append(env[:len(env):len(env)], initEnv+"="+string(cfg)),
+		ExtraFiles:  []*os.File{w},
+		SysProcAttr: attr,
+	}
+
+	us, err := startSession(ctx, cmd, opts, cred)
+	_ = w.Close()
+	if err != nil {
+		_ = r.Close()
+		return nil, isolationStartError(iso, err)
+	}
+	us.init = &initPipe{f: r, dec: json.NewDecoder(r)}
+
+	rep, err := awaitStart(us.init.dec, !iso.PID)
+	if err != nil || !rep.Ready {
+		_ = us.Close()
+		us.startWaiter()
+		_ = us.Wait()
+		switch {
+		case err != nil:
+			return nil, &IsolationError{Op: "init", Err: err}
+		case rep.Op == "lookpath":
+			return nil, &exec.Error{Name: opts.Prog, Err: exec.ErrNotFound}
+		}
+		return nil, &IsolationError{Op: rep.Op, Err: errors.New(rep.Err)}
+	}
+	us.sandbox = rep.Sandbox
+	us.startWaiter()
+	return us, nil
+}
+
+func isolationStartError(iso Isolation, err error) error {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return err
+	}
+	switch {
+	case iso.User && (errno == unix.EPERM || errno == unix.EACCES || errno == unix.ENOSPC || errno == unix.EUSERS):
+		return &IsolationError{Op: "userns", Err: fmt.Errorf("unprivileged user namespaces are disabled: %w", errno)}
+	case errno == unix.EPERM || errno == unix.EINVAL:
+		return &IsolationError{Op: "clone", Err: errno}
+	}
+	return err
+}
+
+// status returns the program's wait status as reported by the init, which
+// is only sent when the init outlives the program in a pid namespace.
+func (p *initPipe) status() (syscall.WaitStatus, bool) {
+	if p == nil {
+		return 0, false
+	}
+	var rep initReport
+	if err := p.dec.Decode(&rep); err != nil || rep.Status == nil {
+		return 0, false
+	}
+	return syscall.WaitStatus(*rep.Status), true
+}
+
+func (p *initPipe) close() {
+	if p != nil {
+		_ = p.f.Close()
+	}
+}
+
+func runInit(raw string) int {
+	report := os.NewFile(uintptr(initReportFd), "ptyx-init")
+	syscall.CloseOnExec(initReportFd)
+	enc := json.NewEncoder(report)
+	fail := func(err error) int {
+		rep := initReport{Op: "init", Err: err.Error()}
+		var ie *IsolationError
+		if errors.As(err, &ie) {
+			rep.Op, rep.Err = ie.Op, ie.Err.Error()
+		}
+		_ = enc.Encode(rep)
+		return 127
+	}
+
+	var cfg initConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return fail(err)
+	}
+	_ = os.Unsetenv(initEnv)
+	if err := setupIsolation(cfg); err != nil {
+		return fail(err)
+	}
+	if cfg.Isolation.PID {
+		return runPID1(cfg, report, fail)
+	}
+	path, err := exec.LookPath(cfg.Prog)
+	if err != nil {
+		return fail(&IsolationError{Op: "lookpath", Err: err})
+	}
+
+	rep := initReport{Ready: true}
+	var sb *sandbox
+	if cfg.Sandbox != nil {
+		// no_new_privs, Landlock and seccomp bind only the calling thread,
+		// which therefore has to be the one that execs.
+		runtime.LockOSThread()
+		if sb, err = prepareSandbox(*cfg.Sandbox); err != nil {
+			return fail(err)
+		}
+		rep.Sandbox = sb.report
+	}
+	// The report is encoded before rlimits apply, so a tight address space
+	// limit cannot starve the encoder.
+	msg, err := json.Marshal(rep)
+	if err != nil {
+		return fail(err)
+	}
+	if sb != nil {
+		if err := sb.setRlimits(); err != nil {
+			return fail(err)
+		}
+	}
+	if cfg.Credential != nil {
+		if err := setCredential(cfg.Credential); err != nil {
+			return fail(err)
+		}
+	}
+	_, _ = report.Write(append(msg, '\n'))
+	if sb != nil {
+		if err := sb.installSeccomp(); err != nil {
+			return fail(&IsolationError{Op: "seccomp", Err: err})
+		}
+	}
+	err = syscall.Exec(path, cfg.argv(), os.Environ())
+	return fail(&IsolationError{Op: "exec", Err: err})
+}
+
+// awaitStart reads the init's first report. An init that execs the program
+// itself reports before the exec, so the pipe, closed on exec, then has to
+// reach its end for the start to be confirmed; anything else is the failure.
+func awaitStart(dec *json.Decoder, execs bool) (initReport, error) {
+	var rep initReport
+	if err := dec.Decode(&rep); err != nil || !rep.Ready || !execs {
+		return rep, err
+	}
+	var after initReport
+	if err := dec.Decode(&after); errors.Is(err, io.EOF) {
+		return rep, nil
+	} else if err != nil {
+		return rep, err
+	}
+	return after, nil
+}
+
+// runPID1 starts the program and reaps every process in the pid namespace
+// until the program exits.
+func runPID1(cfg initConfig, report *os.File, fail func(error) int) int {
+	argv := cfg.argv()
+	env := os.Environ()
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	attr := &syscall.SysProcAttr{Setpgid: true, Foreground: true, Ctty: 0, Credential: cfg.Credential}
+	var path string
+	var stageR, stageW *os.File
+	if cfg.Sandbox != nil {
+		// A second init stage applies the profile to the program alone and
+		// reports on its behalf.
+		stage, err := json.Marshal(initConfig{Prog: cfg.Prog, Args: cfg.Args, Argv0: cfg.Argv0, Sandbox: cfg.Sandbox, Credential: cfg.Credential})
+		if err != nil {
+			return fail(err)
+		}
+		path, argv = "/proc/self/exe", []string{"ptyx-init"}
+		env = append(env, initEnv+"="+string(stage))
+		// The stage reports on a pipe of its own, so its end after the exec
+		// can be seen while the init holds on to the session's pipe.
+		if stageR, stageW, err = os.Pipe(); err != nil {
+			return fail(err)
+		}
+		defer stageR.Close()
+		defer stageW.Close()
+		files = append(files, stageW)
+		attr.Credential = nil
+	} else {
+		var err error
+		if path, err = exec.LookPath(cfg.Prog); err != nil {
+			return fail(&IsolationError{Op: "lookpath", Err: err})
+		}
+	}
+
+	// As pid 1 the init is immune to signals it has no handler for, so the
+	// ones a session may send are caught and passed on to the program's
+	// foreground process group, as they would reach it outside the
+	// namespace.
+	sigs := make(chan os.Signal, 8)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM,
+		syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGALRM, syscall.SIGCONT, syscall.SIGTSTP, syscall.SIGWINCH)
+	proc, err := os.StartProcess(path, argv, &os.ProcAttr{
+		Env:   env,
+		Files: files,
+		Sys:   attr,
+	})
+	if err != nil {
+		return fail(&IsolationError{Op: "exec", Err: err})
+	}
+	enc := json.NewEncoder(report)
+	rep := initReport{Ready: true}
+	if stageR != nil {
+		_ = stageW.Close()
+		if rep, err = awaitStart(json.NewDecoder(stageR), true); err != nil {
+			rep = initReport{Op: "init", Err: err.Error()}
+		}
+	}
+	_ = enc.Encode(rep)
+	go func() {
+		for sig := range sigs {
+			_ = syscall.Kill(-proc.Pid, sig.(syscall.Signal))
+		}
+	}()
+
+	for {
+		var ws unix.WaitStatus
+		pid, err := unix.Wait4(-1, &ws, 0, nil)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 1
+		}
+		if pid != proc.Pid {
+			continue
+		}
+		st := int(ws)
+		_ = enc.Encode(initReport{Status: &st})
+		if ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return ws.ExitStatus()
+	}
+}
+
+// setCredential switches every thread of the init to the session's user.
+func setCredential(c *syscall.Credential) error {
+	groups := make([]int, len(c.Groups))
+	for i, g := range c.Groups {
+		groups[i] = int(g)
+	}
+	if err := syscall.Setgroups(groups); err != nil {
+		return &IsolationError{Op: "credential", Err: fmt.Errorf("setgroups: %w", err)}
+	}
+	if err := syscall.Setgid(int(c.Gid)); err != nil {
+		return &IsolationError{Op: "credential", Err: fmt.Errorf("setgid: %w", err)}
+	}
+	if err := syscall.Setuid(int(c.Uid)); err != nil {
+		return &IsolationError{Op: "credential", Err: fmt.Errorf("setuid: %w", err)}
+	}
+	return nil
+}
+
+func setupIsolation(cfg initConfig) error {
+	iso := cfg.Isolation
+	if iso.Hostname != "" {
+		if err := unix.Sethostname([]byte(iso.Hostname)); err != nil {
+			return &IsolationError{Op: "sethostname", Err: err}
+		}
+	}
+	if iso.mountNS() {
+		if err := setupMounts(iso); err != nil {
+			return err
+		}
+	}
+	if cfg.Dir != "" {
+		if err := os.Chdir(cfg.Dir); err != nil {
+			return &IsolationError{Op: "chdir", Err: err}
+		}
+	}
+	return nil
+}
+
+func setupMounts(iso Isolation) error {
+	// Keep the layout from propagating back to the parent namespace.
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return &IsolationError{Op: "mount", Err: fmt.Errorf("make / private: %w", err)}
+	}
+	root := iso.Root
+	if root != "" {
+		if err := unix.Mount(root, root, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return &IsolationError{Op: "mount", Err: fmt.Errorf("bind %s: %w", root, err)}
+		}
+	}
+	var writable []string
+	for _, m := range iso.Mounts {
+		target := filepath.Join("/", root, m.Target)
+		if err := bindMount(m, target); err != nil {
+			return &IsolationError{Op: "mount", Err: err}
+		}
+		if m.Writable {
+			writable = append(writable, target)
+		}
+	}
+	if root != "" {
+		if err := remountReadOnly(root, writable...); err != nil {
+			return &IsolationError{Op: "mount", Err: err}
+		}
+	}
+	if iso.PID {
+		// Best effort: the kernel refuses a new proc mount where /proc is
+		// partly masked, as in many containers.
+		_ = unix.Mount("proc", filepath.Join("/", root, "proc"), "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+	}
+	if root == "" {
+		return nil
+	}
+	if err := unix.Chdir(root); err != nil {
+		return &IsolationError{Op: "pivot_root", Err: err}
+	}
+	// Stacking the old root under the new one avoids needing a directory
+	// for it; it is then detached.
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return &IsolationError{Op: "pivot_root", Err: err}
+	}
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return &IsolationError{Op: "pivot_root", Err: err}
+	}
+	return unix.Chdir("/")
+}
+
+func bindMount(m BindMount, target string) error {
+	fi, err := os.Stat(m.Source)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(target); os.IsNotExist(err) {
+		if fi.IsDir() {
+			err = os.MkdirAll(target, 0o755)
+		} else if err = os.MkdirAll(filepath.Dir(target), 0o755); err == nil {
+			var f *os.File
+			if f, err = os.OpenFile(target, os.O_CREATE|os.O_WRONLY, 0o644); err == nil {
+				err = f.Close()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := unix.Mount(m.Source, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s on %s: %w", m.Source, target, err)
+	}
+	if !m.Writable {
+		return remountReadOnly(target)
+	}
+	return nil
+}
+
+// remountReadOnly makes a bind mount and every mount below it read-only,
+// except those at or below one of keep. A read-only bind does not recurse,
+// so each submount listed in mountinfo is remounted on its own.
+func remountReadOnly(target string, keep ...string) error {
+	b, err := os.ReadFile(filepath.Join(procDir, "self", "mountinfo"))
+	if err != nil {
+		return err
+	}
+	target = resolvePath(target)
+	for _, p := range append([]string{target}, mountsBelow(string(b), target)...) {
+		if slices.ContainsFunc(keep, func(k string) bool { return pathWithin(p, resolvePath(k)) }) {
+			continue
+		}
+		if err := remountOneReadOnly(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mountsBelow returns the mount points in a mountinfo listing that lie
+// strictly below dir, in mount order.
+func mountsBelow(mountinfo, dir string) []string {
+	var out []string
+	for _, line := range strings.Split(mountinfo, "\n") {
+		f := strings.Fields(line)
+		if len(f) < 5 {
+			continue
+		}
+		p := unescapeMountinfo(f[4])
+		if p != dir && pathWithin(p, dir) && !slices.Contains(out, p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// unescapeMountinfo decodes the octal escapes mountinfo uses for space, tab,
+// newline and backslash.
+func unescapeMountinfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func pathWithin(p, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+// resolvePath returns path with symlinks resolved, as mountinfo shows it.
+func resolvePath(path string) string {
+	if r, err := filepath.EvalSymlinks(path); err == nil {
+		return r
+	}
+	return filepath.Clean(path)
+}
+
+// remountOneReadOnly makes a single mount read-only. Inside a user namespace
+// the remount must keep the flags the original mount was locked with.
+func remountOneReadOnly(target string) error {
+	var st unix.Statfs_t
+	if err := unix.Statfs(target, &st); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY)
+	for stFlag, msFlag := range map[uint64]uintptr{
+		unix.ST_NOSUID:     unix.MS_NOSUID,
+		unix.ST_NODEV:      unix.MS_NODEV,
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if uint64(st.Flags)&stFlag != 0 {
+			flags |= msFlag
+		}
+	}
+	if err := unix.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", target, err)
+	}
+	return nil
+}
@@ -0,0 +1,371 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// runIsolated spawns script under iso and returns its output and Wait error.
+func runIsolated(t *testing.T, iso Isolation, script string) (string, error) {
+	t.Helper()
+	s, err := Spawn(context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", script}, Isolation: &iso})
+	if errors.Is(err, ErrUserNamespacesDisabled) {
+		t.Skipf("user namespaces unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Spawn() failed: %v", err)
+	}
+	defer s.Close()
+	out := make(chan string, 1)
+	go func() {
+		b, _ := io.ReadAll(s.PtyReader())
+		out <- string(b)
+	}()
+	werr := waitTimeout(t, s, 5*time.Second)
+	_ = s.Close()
+	return strings.ReplaceAll(<-out, "\r\n", "\n"), werr
+}
+
+func TestIsolation_Namespaces(t *testing.T) {
+	tests := []struct {
+		name   string
+		iso    Isolation
+		script string
+		want   string
+	}{
+		{"User", Isolation{User: true}, "id -u", "0\n"},
+		{"PID", Isolation{User: true, PID: true}, "echo $PPID", "1\n"},
+		{"Hostname", Isolation{User: true, Hostname: "sandbox"}, "cat /proc/sys/kernel/hostname", "sandbox\n"},
+		{"Network", Isolation{User: true, Network: true}, "grep -c : /proc/net/dev", "1\n"},
+		{"Env", Isolation{User: true}, "echo ${" + initEnv + ":-unset}", "unset\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runIsolated(t, tt.iso, tt.script)
+			if err != nil {
+				t.Fatalf("Wait() = %v, output %q", err, out)
+			}
+			if out != tt.want {
+				t.Errorf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsolation_BindMounts(t *testing.T) {
+	ro, rw, target := t.TempDir(), t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(ro, "hello"), []byte("hi\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(ro, "scratch"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	iso := Isolation{User: true, Mounts: []BindMount{
+		{Source: ro, Target: target},
+		{Source: rw, Target: filepath.Join(target, "scratch"), Writable: true},
+	}}
+	out, err := runIsolated(t, iso, `cd `+target+` && cat hello && (touch new 2>/dev/null && echo writable || echo readonly) && touch scratch/x && echo ok`)
+	if err != nil {
+		t.Fatalf("Wait() = %v, output %q", err, out)
+	}
+	if out != "hi\nreadonly\nok\n" {
+		t.Errorf("output = %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(rw, "x")); err != nil {
+		t.Errorf("write through the writable mount not visible: %v", err)
+	}
+	if entries, _ := os.ReadDir(target); len(entries) != 0 {
+		t.Errorf("the bind layout leaked into the parent namespace: %v", entries)
+	}
+}
+
+func TestIsolation_NestedMountsReadOnly(t *testing.T) {
+	ro, target := t.TempDir(), t.TempDir()
+	nested := filepath.Join(ro, "nested")
+	if err := os.Mkdir(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mount("tmpfs", nested, "tmpfs", 0, ""); err != nil {
+		t.Skipf("cannot mount a tmpfs: %v", err)
+	}
+	t.Cleanup(func() { _ = syscall.Unmount(nested, syscall.MNT_DETACH) })
+
+	iso := Isolation{User: true, Mounts: []BindMount{{Source: ro, Target: target}}}
+	out, err := runIsolated(t, iso, `touch `+target+`/nested/x 2>/dev/null && echo writable || echo readonly`)
+	if err != nil {
+		t.Fatalf("Wait() = %v, output %q", err, out)
+	}
+	if out != "readonly\n" {
+		t.Errorf("output = %q, want the submount read-only", out)
+	}
+	if _, err := os.Stat(filepath.Join(nested, "x")); err == nil {
+		t.Error("write through the read-only bind's submount reached the host")
+	}
+
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mount("tmpfs", filepath.Join(root, "nested"), "tmpfs", 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = syscall.Unmount(filepath.Join(root, "nested"), syscall.MNT_DETACH) })
+	rw := t.TempDir()
+	iso = Isolation{User: true, Root: root, Mounts: []BindMount{{Source: rw, Target: "/scratch", Writable: true}}}
+	for _, dir := range []string{"/bin", "/lib", "/lib64", "/usr"} {
+		if fi, err := os.Lstat(dir); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+			link, _ := os.Readlink(dir)
+			if err := os.Symlink(link, filepath.Join(root, dir)); err != nil {
+				t.Fatal(err)
+			}
+		} else if err == nil {
+			iso.Mounts = append(iso.Mounts, BindMount{Source: dir, Target: dir})
+		}
+	}
+	out, err = runIsolated(t, iso, `touch /nested/x 2>/scratch/err && echo writable || echo readonly; touch /scratch/x && echo ok`)
+	if err != nil {
+		t.Fatalf("Wait() = %v, output %q", err, out)
+	}
+	if out != "readonly\nok\n" {
+		t.Errorf("output = %q, want a read-only submount of Root and a writable mount", out)
+	}
+}
+
+func TestMountsBelow(t *testing.T) {
+	info := `22 1 0:21 / / rw - ext4 /dev/sda1 rw
+30 22 0:30 / /srv rw - tmpfs tmpfs rw
+31 30 0:31 / /srv/a rw - tmpfs tmpfs rw
+32 31 0:32 / /srv/a/b\040c rw - tmpfs tmpfs rw
+33 22 0:33 / /srvx rw - tmpfs tmpfs rw
+34 30 0:31 / /srv/a rw - tmpfs tmpfs rw
+`
+	got := mountsBelow(info, "/srv")
+	want := []string{"/srv/a", "/srv/a/b c"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("mountsBelow() = %q, want %q", got, want)
+	}
+	if got := mountsBelow(info, "/"); len(got) != 4 {
+		t.Errorf("mountsBelow(/) = %q, want every other mount", got)
+	}
+}
+
+func TestIsolation_Root(t *testing.T) {
+	root := t.TempDir()
+	iso := Isolation{User: true, PID: true, Root: root}
+	for _, dir := range []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/usr"} {
+		fi, err := os.Lstat(dir)
+		if err != nil {
+			continue
+		}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			link, _ := os.Readlink(dir)
+			if err := os.Symlink(link, filepath.Join(root, dir)); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		iso.Mounts = append(iso.Mounts, BindMount{Source: dir, Target: dir})
+	}
+	if err := os.Mkdir(filepath.Join(root, "proc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runIsolated(t, iso, `ls /; touch /x 2>/dev/null && echo writable || echo readonly; ls /proc | grep -c '^[0-9]'`)
+	if err != nil {
+		t.Fatalf("Wait() = %v, output %q", err, out)
+	}
+	lines := strings.Fields(out)
+	if len(lines) < 2 || lines[len(lines)-2] != "readonly" {
+		t.Errorf("output = %q, want a read-only root", out)
+	}
+	if strings.Contains(out, "home") || strings.Contains(out, "etc") {
+		t.Errorf("host root visible: %q", out)
+	}
+	if n := lines[len(lines)-1]; n != "3" && n != "4" {
+		t.Errorf("processes visible in /proc = %s, want only the namespace's own", n)
+	}
+}
+
+func TestIsolation_ExitStatus(t *testing.T) {
+	iso := Isolation{User: true, PID: true}
+	var exitErr *ExitError
+	if _, err := runIsolated(t, iso, "exit 7"); !errors.As(err, &exitErr) || exitErr.ExitCode != 7 {
+		t.Errorf("Wait() = %v, want exit status 7", err)
+	}
+	if _, err := runIsolated(t, iso, "kill -TERM $$"); !errors.As(err, &exitErr) || !exitErr.Signaled || exitErr.Signal != SIGTERM {
+		t.Errorf("Wait() = %v, want termination by SIGTERM", err)
+	}
+}
+
+func TestIsolation_Reaping(t *testing.T) {
+	out, err := runIsolated(t, Isolation{User: true, PID: true, Mount: true},
+		`sh -c 'true & exit 0'; sleep 0.2; grep -l '^[0-9]* ([^)]*) Z' /proc/[0-9]*/stat | wc -l`)
+	if err != nil {
+		t.Fatalf("Wait() = %v, output %q", err, out)
+	}
+	if strings.TrimSpace(out) != "0" {
+		t.Errorf("zombies = %q, want orphans reaped by the init", out)
+	}
+}
+
+func TestIsolation_SignalForwarding(t *testing.T) {
+	iso := Isolation{User: true, PID: true}
+	s := spawnReady(t, context.Background(), SpawnOpts{
+		Prog:      "sh",
+		Args:      []string{"-c", "trap 'exit 3' TERM; echo ready; while :; do sleep 0.05; done"},
+		Isolation: &iso,
+	})
+	if err := s.Signal(SIGTERM); err != nil {
+		t.Fatalf("Signal() failed: %v", err)
+	}
+	var exitErr *ExitError
+	if err := waitTimeout(t, s, 3*time.Second); !errors.As(err, &exitErr) || exitErr.ExitCode != 3 {
+		t.Fatalf("Wait() = %v, want exit status 3 from the trapped SIGTERM", err)
+	}
+}
+
+func TestIsolation_SignalReachesGroup(t *testing.T) {
+	iso := Isolation{User: true, PID: true}
+	s := spawnReady(t, context.Background(), SpawnOpts{
+		Prog:      "sh",
+		Args:      []string{"-c", `trap : TERM; sh -c 'trap "exit 4" TERM; echo ready; while :; do sleep 0.05; done'; exit $?`},
+		Isolation: &iso,
+	})
+	if err := s.Signal(SIGTERM); err != nil {
+		t.Fatalf("Signal() failed: %v", err)
+	}
+	var exitErr *ExitError
+	if err := waitTimeout(t, s, 3*time.Second); !errors.As(err, &exitErr) || exitErr.ExitCode != 4 {
+		t.Fatalf("Wait() = %v, want exit status 4 from the child's trap", err)
+	}
+}
+
+func TestIsolation_Kill(t *testing.T) {
+	iso := Isolation{User: true, PID: true}
+	s := spawnReady(t, context.Background(), SpawnOpts{
+		Prog:      "sh",
+		Args:      []string{"-c", "trap '' TERM INT; echo ready; while :; do sleep 0.05; done"},
+		Isolation: &iso,
+	})
+	if err := s.Kill(); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+	var exitErr *ExitError
+	if err := waitTimeout(t, s, 3*time.Second); !errors.As(err, &exitErr) || exitErr.Signal != SIGKILL {
+		t.Fatalf("Wait() = %v, want SIGKILL", err)
+	}
+}
+
+func TestIsolation_SetupErrors(t *testing.T) {
+	_, err := Spawn(context.Background(), SpawnOpts{Prog: "a-program-that-does-not-exist-12345", Isolation: &Isolation{User: true}})
+	var execErr *exec.Error
+	if errors.Is(err, ErrUserNamespacesDisabled) {
+		t.Skip("user namespaces unavailable")
+	}
+	if !errors.As(err, &execErr) {
+		t.Errorf("Spawn() = %v, want *exec.Error", err)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	_, err = Spawn(context.Background(), SpawnOpts{Prog: "true", Isolation: &Isolation{User: true, Mounts: []BindMount{{Source: missing, Target: "/mnt"}}}})
+	var isoErr *IsolationError
+	if !errors.As(err, &isoErr) || isoErr.Op != "mount" || !strings.Contains(err.Error(), missing) {
+		t.Errorf("Spawn() = %v, want a mount IsolationError naming the source", err)
+	}
+
+	_, err = Spawn(context.Background(), SpawnOpts{Prog: "true", Dir: missing, Isolation: &Isolation{User: true}})
+	if !errors.As(err, &isoErr) || isoErr.Op != "chdir" {
+		t.Errorf("Spawn() = %v, want a chdir IsolationError", err)
+	}
+}
+
+func TestIsolationStartError(t *testing.T) {
+	startErr := &fs.PathError{Op: "fork/exec", Path: "/proc/self/exe", Err: syscall.EPERM}
+	err := isolationStartError(Isolation{User: true}, startErr)
+	if !errors.Is(err, ErrUserNamespacesDisabled) || !errors.Is(err, syscall.EPERM) {
+		t.Errorf("isolationStartError() = %v, want ErrUserNamespacesDisabled wrapping EPERM", err)
+	}
+	if !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("error %q does not say user namespaces are disabled", err)
+	}
+
+	err = isolationStartError(Isolation{PID: true}, startErr)
+	var isoErr *IsolationError
+	if !errors.As(err, &isoErr) || isoErr.Op != "clone" || errors.Is(err, ErrUserNamespacesDisabled) {
+		t.Errorf("isolationStartError() = %v, want a clone IsolationError", err)
+	}
+
+	other := errors.New("boom")
+	if err := isolationStartError(Isolation{User: true}, other); err != other {
+		t.Errorf("isolationStartError() = %v, want the error unchanged", err)
+	}
+}
+
+func TestSpawnIsolated_WithoutInit(t *testing.T) {
+	initCalled.Store(false)
+	defer initCalled.Store(true)
+	_, err := Spawn(context.Background(), SpawnOpts{Prog: "true", Sandbox: &Sandbox{NoNewPrivs: true}})
+	var isoErr *IsolationError
+	if !errors.As(err, &isoErr) || isoErr.Op != "init" {
+		t.Errorf("Spawn() = %v, want an init IsolationError asking for Init", err)
+	}
+}
+
+func TestRunInit_Failures(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    string
+		wantOp string
+	}{
+		{"BadConfig", "{", "init"},
+		{"Chdir", `{"Prog":"true","Dir":"/nonexistent-ptyx-dir"}`, "chdir"},
+		{"LookPath", `{"Prog":"a-program-that-does-not-exist-12345"}`, "lookpath"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			// runInit wraps the descriptor in an *os.File that owns it, so it
+			// gets a copy rather than w's.
+			fd, err := syscall.Dup(int(w.Fd()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			old := initReportFd
+			initReportFd = fd
+			defer func() { initReportFd = old }()
+
+			if code := runInit(tt.cfg); code != 127 {
+				t.Errorf("runInit() = %d, want 127", code)
+			}
+			_ = w.Close()
+			p := &initPipe{f: r, dec: json.NewDecoder(r)}
+			var rep initReport
+			if err := p.dec.Decode(&rep); err != nil || rep.Ready || rep.Op != tt.wantOp || rep.Err == "" {
+				t.Errorf("report = %+v, %v; want a %q failure", rep, err, tt.wantOp)
+			}
+		})
+	}
+}
+
+func TestBindMount_MissingSource(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	if err := bindMount(BindMount{Source: missing}, t.TempDir()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("bindMount() = %v, want ErrNotExist", err)
+	}
+}
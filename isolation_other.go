@@ -0,0 +1,18 @@
+//go:build unix && !linux
+
+package ptyx
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+)
+
+type initPipe struct{}
+
+func (*initPipe) status() (syscall.WaitStatus, bool) { return 0, false }
+func (*initPipe) close()                             {}
+
+func spawnIsolated(context.Context, SpawnOpts, *syscall.Credential) (Session, error) {
+	return nil, fmt.Errorf("ptyx: isolation: %w", ErrUnsupported)
+}
@@ -47,7 +47,15 @@ func (m *mux) Start(c Console, s Session) error {
 
 	go func() {
 		defer m.wg.Done()
-		_, _ = io.Copy(c.Out(), s.PtyReader())
+		var events <-chan PacketEvent
+		if ps, ok := As[PacketEventSession](s); ok {
+			events = ps.PacketEvents()
+		}
+		if events != nil {
+			copyPackets(c.Out(), s.PtyReader(), events)
+		} else {
+			_, _ = io.Copy(c.Out(), s.PtyReader())
+		}
 
 		m.closeStdinOnce.Do(func() { _ = s.CloseStdin() })
 	}()
@@ -70,3 +78,117 @@ func (m *mux) Stop() error {
 	m.wg.Wait()
 	return nil
 }
+
+type muxItem struct {
+	data []byte
+	ev   PacketEvent
+}
+
+// flushQueue drops the output still queued for the console, keeping the
+// events so a pending STOP or START is not lost with it.
+func flushQueue(queue chan muxItem) {
+	var kept []muxItem
+	for len(queue) > 0 {
+		select {
+		case it := <-queue:
+			if it.data == nil {
+				kept = append(kept, it)
+			}
+		default:
+		}
+	}
+	for _, it := range kept {
+		queue <- it
+	}
+}
+
+// copyPackets forwards pty output like io.Copy while honouring packet-mode
+// events: output is held between STOP and START, and output not yet written
+// to the console is dropped when the terminal flushes its output queue.
+func copyPackets(w io.Writer, r io.Reader, events <-chan PacketEvent) {
+	queue := make(chan muxItem, 64)
+	failed := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		var held [][]byte
+		stopped, broken := false, false
+		write := func(b []byte) {
+			if broken {
+				return
+			}
+			if _, err := w.Write(b); err != nil {
+				broken = true
+				close(failed)
+			}
+		}
+		for it := range queue {
+			if it.ev&PacketFlushWrite != 0 {
+				held = nil
+			}
+			if it.ev&PacketStop != 0 {
+				stopped = true
+			}
+			if it.ev&PacketStart != 0 {
+				stopped = false
+				for _, b := range held {
+					write(b)
+				}
+				held = nil
+			}
+			if it.data == nil {
+				continue
+			}
+			if stopped {
+				held = append(held, it.data)
+			} else {
+				write(it.data)
+			}
+		}
+		for _, b := range held {
+			write(b)
+		}
+	}()
+
+	defer func() {
+		close(queue)
+		<-done
+	}()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+	drain:
+		for events != nil {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					events = nil
+					break drain
+				}
+				if ev&PacketFlushWrite != 0 {
+					flushQueue(queue)
+				}
+				queue <- muxItem{ev: ev}
+			default:
+				break drain
+			}
+		}
+		if n > 0 {
+			select {
+			case queue <- muxItem{data: append([]byte(nil), buf[:n]...)}:
+			case <-failed:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+		select {
+		case <-failed:
+			return
+		default:
+		}
+	}
+}
@@ -19,6 +19,12 @@ type mockCloser struct {
 
 func (mc *mockCloser) Close() error { return nil }
 
+// wrappedSession hides the optional interfaces of its Session, as a
+// recorder does, and exposes it through Unwrap.
+type wrappedSession struct{ Session }
+
+func (w wrappedSession) Unwrap() Session { return w.Session }
+
 func TestMux(t *testing.T) {
 	t.Run("ConsoleToPty", func(t *testing.T) {
 		consoleInput := "hello from console"
@@ -75,6 +81,36 @@ func TestMux(t *testing.T) {
 		}
 	})
 
+	for name, wrap := range map[string]func(Session) Session{
+		"PtyToConsole_PacketMode":        func(s Session) Session { return s },
+		"PtyToConsole_PacketModeWrapped": func(s Session) Session { return wrappedSession{s} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := newMockConsole("")
+			s := newMockSession("")
+			ptyOutR, ptyOutW := io.Pipe()
+			pr := newPacketReader(ptyOutR)
+			s.ptyOut, s.events = pr, pr.events
+
+			m := NewMux()
+			if err := m.Start(c, wrap(s)); err != nil {
+				t.Fatalf("Mux.Start() failed: %v", err)
+			}
+			_, _ = ptyOutW.Write([]byte{byte(PacketStop)})
+			_, _ = ptyOutW.Write([]byte("\x00discarded"))
+			_, _ = ptyOutW.Write([]byte{byte(PacketFlushWrite | PacketStart)})
+			_, _ = ptyOutW.Write([]byte("\x00shown"))
+			ptyOutW.Close()
+
+			if err := m.Stop(); err != nil {
+				t.Fatalf("Mux.Stop() failed: %v", err)
+			}
+			if got := c.outBuf.String(); got != "shown" {
+				t.Errorf("console output = %q, want %q", got, "shown")
+			}
+		})
+	}
+
 	t.Run("Stop without Start", func(t *testing.T) {
 		m := NewMux()
 		if err := m.Stop(); err != nil {
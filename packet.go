@@ -0,0 +1,104 @@
+package ptyx
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// PacketEvent is a set of terminal status changes reported in pty packet
+// mode (TIOCPKT).
+type PacketEvent uint8
+
+const (
+	PacketFlushRead  PacketEvent = 0x01 // pending input was discarded
+	PacketFlushWrite PacketEvent = 0x02 // pending output was discarded, e.g. after ^C
+	PacketStop       PacketEvent = 0x04 // output stopped by ^S
+	PacketStart      PacketEvent = 0x08 // output restarted by ^Q
+	PacketNoStop     PacketEvent = 0x10 // IXON turned off, ^S/^Q are plain input
+	PacketDoStop     PacketEvent = 0x20 // IXON turned on
+	PacketIoctl      PacketEvent = 0x40 // termios changed while in external processing mode
+)
+
+func (e PacketEvent) String() string {
+	names := []string{"FLUSHREAD", "FLUSHWRITE", "STOP", "START", "NOSTOP", "DOSTOP", "IOCTL"}
+	var parts []string
+	for i, n := range names {
+		if e&(1<<i) != 0 {
+			parts = append(parts, n)
+		}
+	}
+	if len(parts) == 0 {
+		return "DATA"
+	}
+	return strings.Join(parts, "|")
+}
+
+// packetReader strips the leading status byte from each packet-mode read,
+// returning data packets and publishing status packets as events.
+type packetReader struct {
+	r         io.Reader
+	events    chan PacketEvent
+	buf       []byte
+	pending   PacketEvent
+	closeOnce sync.Once
+}
+
+func newPacketReader(r io.Reader) *packetReader {
+	return &packetReader{r: r, events: make(chan PacketEvent, 16)}
+}
+
+func (pr *packetReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if cap(pr.buf) < len(p)+1 {
+		pr.buf = make([]byte, len(p)+1)
+	}
+	buf := pr.buf[:len(p)+1]
+	pr.publish(0)
+	for {
+		n, err := pr.r.Read(buf)
+		if n > 0 && buf[0] != 0 {
+			pr.publish(PacketEvent(buf[0]))
+			n = 0
+		} else if n > 0 {
+			n = copy(p, buf[1:n])
+		}
+		if err != nil {
+			pr.publish(0)
+			pr.closeOnce.Do(func() { close(pr.events) })
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// publish hands ev to the events channel without blocking. While the channel
+// is full, events are merged into one pending event that is retried on the
+// next Read, so flow control changes are coalesced rather than lost.
+func (pr *packetReader) publish(ev PacketEvent) {
+	pr.pending = mergeEvents(pr.pending, ev)
+	if pr.pending == 0 {
+		return
+	}
+	select {
+	case pr.events <- pr.pending:
+		pr.pending = 0
+	default:
+	}
+}
+
+// mergeEvents folds ev into old. STOP/START and NOSTOP/DOSTOP are states, so
+// the newer one replaces the older; the other bits accumulate.
+func mergeEvents(old, ev PacketEvent) PacketEvent {
+	if ev&(PacketStop|PacketStart) != 0 {
+		old &^= PacketStop | PacketStart
+	}
+	if ev&(PacketNoStop|PacketDoStop) != 0 {
+		old &^= PacketNoStop | PacketDoStop
+	}
+	return old | ev
+}
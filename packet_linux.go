@@ -0,0 +1,7 @@
+//go:build linux
+
+package ptyx
+
+import "golang.org/x/sys/unix"
+
+func enablePacketMode(fd int) error { return unix.IoctlSetPointerInt(fd, unix.TIOCPKT, 1) }
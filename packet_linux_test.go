@@ -0,0 +1,51 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUnixSession_PacketMode(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{
+		Prog:       "sh",
+		Args:       []string{"-c", "echo ready; read x"},
+		PacketMode: true,
+	})
+	events := s.(PacketEventSession).PacketEvents()
+	if events == nil {
+		t.Fatal("PacketEvents() = nil with PacketMode on")
+	}
+
+	expectEvent := func(want PacketEvent) {
+		t.Helper()
+		deadline := time.After(3 * time.Second)
+		for {
+			select {
+			case ev := <-events:
+				if ev&want != 0 {
+					return
+				}
+			case <-deadline:
+				t.Fatalf("no %v event", want)
+			}
+		}
+	}
+
+	_, _ = s.PtyWriter().Write([]byte{0x13})
+	expectEvent(PacketStop)
+	_, _ = s.PtyWriter().Write([]byte{0x11})
+	expectEvent(PacketStart)
+	_, _ = s.PtyWriter().Write([]byte("\n"))
+	_ = waitTimeout(t, s, 3*time.Second)
+}
+
+func TestUnixSession_PacketModeOff(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo ready"}})
+	if s.(PacketEventSession).PacketEvents() != nil {
+		t.Error("PacketEvents() should be nil without PacketMode")
+	}
+	_ = waitTimeout(t, s, 3*time.Second)
+}
@@ -0,0 +1,5 @@
+//go:build unix && !linux
+
+package ptyx
+
+func enablePacketMode(int) error { return ErrUnsupported }
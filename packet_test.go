@@ -0,0 +1,201 @@
+package ptyx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPacketEvent_String(t *testing.T) {
+	tests := map[PacketEvent]string{
+		0:                                  "DATA",
+		PacketStop:                         "STOP",
+		PacketFlushRead | PacketFlushWrite: "FLUSHREAD|FLUSHWRITE",
+		PacketDoStop | PacketIoctl:         "DOSTOP|IOCTL",
+	}
+	for ev, want := range tests {
+		if got := ev.String(); got != want {
+			t.Errorf("PacketEvent(%#x).String() = %q, want %q", uint8(ev), got, want)
+		}
+	}
+}
+
+type chunkReader struct {
+	chunks [][]byte
+	err    error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestPacketReader(t *testing.T) {
+	src := &chunkReader{
+		chunks: [][]byte{{0, 'h', 'i'}, {byte(PacketStop)}, {byte(PacketStart)}, {0, '!'}},
+		err:    io.EOF,
+	}
+	pr := newPacketReader(src)
+
+	out, err := io.ReadAll(pr)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if string(out) != "hi!" {
+		t.Errorf("data = %q, want %q", out, "hi!")
+	}
+	var got []PacketEvent
+	for ev := range pr.events {
+		got = append(got, ev)
+	}
+	if len(got) != 2 || got[0] != PacketStop || got[1] != PacketStart {
+		t.Errorf("events = %v, want [STOP START]", got)
+	}
+	if n, err := pr.Read(nil); n != 0 || err != nil {
+		t.Errorf("Read(nil) = %d, %v", n, err)
+	}
+}
+
+func TestPacketReader_DropsWhenFull(t *testing.T) {
+	var chunks [][]byte
+	for i := 0; i < 40; i++ {
+		chunks = append(chunks, []byte{byte(PacketStop)})
+	}
+	pr := newPacketReader(&chunkReader{chunks: chunks, err: errors.New("boom")})
+	if _, err := pr.Read(make([]byte, 8)); err == nil || err.Error() != "boom" {
+		t.Fatalf("Read() = %v, want the source error", err)
+	}
+	if n := len(pr.events); n != cap(pr.events) {
+		t.Errorf("buffered %d events, want the channel to fill without blocking", n)
+	}
+}
+
+func TestPacketReader_MergesWhenFull(t *testing.T) {
+	chunks := [][]byte{}
+	for i := 0; i < 16; i++ {
+		chunks = append(chunks, []byte{byte(PacketFlushRead)})
+	}
+	chunks = append(chunks, []byte{byte(PacketStop)}, []byte{byte(PacketStart | PacketFlushWrite)}, []byte{0, 'x'})
+	pr := newPacketReader(&chunkReader{chunks: chunks, err: io.EOF})
+	if n, err := pr.Read(make([]byte, 8)); n != 1 || err != nil {
+		t.Fatalf("Read() = %d, %v", n, err)
+	}
+	for i := 0; i < 16; i++ {
+		<-pr.events
+	}
+	if _, err := pr.Read(make([]byte, 8)); err != io.EOF {
+		t.Fatalf("Read() = %v, want EOF", err)
+	}
+	if ev := <-pr.events; ev != PacketStart|PacketFlushWrite {
+		t.Errorf("merged event = %v, want START|FLUSHWRITE", ev)
+	}
+}
+
+func TestMergeEvents(t *testing.T) {
+	tests := []struct{ old, ev, want PacketEvent }{
+		{0, PacketStop, PacketStop},
+		{PacketStop, PacketStart, PacketStart},
+		{PacketStart | PacketFlushRead, PacketStop, PacketStop | PacketFlushRead},
+		{PacketNoStop | PacketStop, PacketDoStop, PacketDoStop | PacketStop},
+		{PacketStop, PacketFlushWrite, PacketStop | PacketFlushWrite},
+	}
+	for _, tt := range tests {
+		if got := mergeEvents(tt.old, tt.ev); got != tt.want {
+			t.Errorf("mergeEvents(%v, %v) = %v, want %v", tt.old, tt.ev, got, tt.want)
+		}
+	}
+}
+
+func TestFlushQueue(t *testing.T) {
+	queue := make(chan muxItem, 8)
+	queue <- muxItem{data: []byte("a")}
+	queue <- muxItem{ev: PacketStop}
+	queue <- muxItem{data: []byte("b")}
+	queue <- muxItem{ev: PacketStart}
+	flushQueue(queue)
+	close(queue)
+	var got []PacketEvent
+	for it := range queue {
+		if it.data != nil {
+			t.Errorf("data %q survived the flush", it.data)
+		}
+		got = append(got, it.ev)
+	}
+	if len(got) != 2 || got[0] != PacketStop || got[1] != PacketStart {
+		t.Errorf("kept events = %v, want [STOP START]", got)
+	}
+}
+
+func TestCopyPackets(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []any // PacketEvent or data written by the pty in packet mode
+		want  string
+	}{
+		{"Plain", []any{"a", "b"}, "ab"},
+		{"StopStart", []any{PacketStop, "held", PacketStart, "x"}, "heldx"},
+		{"FlushWhileStopped", []any{PacketStop, "junk", PacketFlushWrite | PacketStart, "z"}, "z"},
+		{"EndWhileStopped", []any{PacketStop, "late"}, "late"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w := io.Pipe()
+			pr := newPacketReader(r)
+			var out bytes.Buffer
+			done := make(chan struct{})
+			go func() {
+				copyPackets(&out, pr, pr.events)
+				close(done)
+			}()
+			for _, st := range tt.steps {
+				switch v := st.(type) {
+				case PacketEvent:
+					_, _ = w.Write([]byte{byte(v)})
+				case string:
+					_, _ = w.Write(append([]byte{0}, v...))
+				}
+			}
+			_ = w.Close()
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				t.Fatal("copyPackets did not return")
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("console gone") }
+
+func TestCopyPackets_WriteError(t *testing.T) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		copyPackets(failingWriter{}, pr, make(chan PacketEvent))
+		close(done)
+	}()
+	go func() {
+		for {
+			if _, err := pw.Write([]byte("x")); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("copyPackets should stop after a console write error")
+	}
+	_ = pr.Close()
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var pidfdOpen = unix.PidfdOpen
+
+// pidfd is a process handle that, unlike a pid, cannot come to refer to
+// another process once the child is reaped.
+type pidfd struct {
+	fd int
+	f  *os.File
+}
+
+func openPidfd(pid int) *pidfd {
+	fd, err := pidfdOpen(pid, unix.PIDFD_NONBLOCK)
+	if err == unix.EINVAL {
+		// PIDFD_NONBLOCK needs Linux 5.10; pidfd_open itself is 5.3.
+		if fd, err = pidfdOpen(pid, 0); err == nil {
+			err = unix.SetNonblock(fd, true)
+		}
+	}
+	if err != nil {
+		if fd > 0 {
+			_ = unix.Close(fd)
+		}
+		return nil
+	}
+	return &pidfd{fd: fd, f: os.NewFile(uintptr(fd), "pidfd")}
+}
+
+func (p *pidfd) signal(sig syscall.Signal) error {
+	rc, err := p.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) { serr = unix.PidfdSendSignal(int(fd), sig, nil, 0) }); err != nil {
+		return err
+	}
+	return serr
+}
+
+func (p *pidfd) close() { _ = p.f.Close() }
+
+// awaitExit blocks until pid has exited without reaping it, so the caller
+// can fence off signals before cmd.Wait frees the pid. The pidfd is waited
+// on through the runtime poller and so does not tie up a thread.
+func awaitExit(p *pidfd, pid int) bool {
+	if p != nil {
+		if rc, err := p.f.SyscallConn(); err == nil {
+			err = rc.Read(func(fd uintptr) bool {
+				n, err := unix.Poll([]unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}, 0)
+				return err == nil && n > 0
+			})
+			if err == nil {
+				return true
+			}
+		}
+	}
+	var info unix.Siginfo
+	for {
+		err := unix.Waitid(unix.P_PID, pid, &info, unix.WEXITED|unix.WNOWAIT, nil)
+		if err != unix.EINTR {
+			return err == nil
+		}
+	}
+}
@@ -0,0 +1,113 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func exitFdReadable(t *testing.T, fd uintptr, timeout time.Duration) bool {
+	t.Helper()
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		n, err := unix.Poll(fds, int(timeout/time.Millisecond))
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("poll: %v", err)
+		}
+		return n > 0
+	}
+}
+
+func TestUnixSession_ExitFd(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo ready; read x"}})
+	fd, err := s.(ExitFdSession).ExitFd()
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip("pidfd_open not available")
+	}
+	if err != nil {
+		t.Fatalf("ExitFd() failed: %v", err)
+	}
+	if exitFdReadable(t, fd, 0) {
+		t.Fatal("exit fd readable while the child is running")
+	}
+	_, _ = s.PtyWriter().Write([]byte("\n"))
+	if !exitFdReadable(t, fd, 3*time.Second) {
+		t.Fatal("exit fd not readable after the child exited")
+	}
+	if err := waitTimeout(t, s, 3*time.Second); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+}
+
+func TestUnixSession_SignalAfterExit(t *testing.T) {
+	for _, name := range []string{"pidfd", "fallback"} {
+		t.Run(name, func(t *testing.T) {
+			if name == "fallback" {
+				old := pidfdOpen
+				pidfdOpen = func(int, int) (int, error) { return -1, unix.ENOSYS }
+				defer func() { pidfdOpen = old }()
+			}
+			s := spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo ready; read x"}})
+			if _, err := s.(ExitFdSession).ExitFd(); name == "fallback" && !errors.Is(err, ErrUnsupported) {
+				t.Errorf("ExitFd() = %v, want ErrUnsupported without pidfd", err)
+			}
+			_, _ = s.PtyWriter().Write([]byte("\n"))
+			if err := waitTimeout(t, s, 3*time.Second); err != nil {
+				t.Fatalf("Wait() = %v", err)
+			}
+			for what, err := range map[string]error{
+				"Kill":             s.Kill(),
+				"Signal":           s.Signal(SIGTERM),
+				"SignalForeground": s.SignalForeground(SIGTERM),
+			} {
+				if !errors.Is(err, os.ErrProcessDone) {
+					t.Errorf("%s() after exit = %v, want os.ErrProcessDone", what, err)
+				}
+			}
+		})
+	}
+}
+
+func TestUnixSession_KillViaPidfd(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo ready; while :; do sleep 0.05; done"}})
+	if err := s.Signal(SIGTERM); err != nil {
+		t.Fatalf("Signal() failed: %v", err)
+	}
+	var exitErr *ExitError
+	if err := waitTimeout(t, s, 3*time.Second); !errors.As(err, &exitErr) || exitErr.Signal != SIGTERM {
+		t.Fatalf("Wait() = %v, want termination by SIGTERM", err)
+	}
+}
+
+func TestOpenPidfd_NoNonblockFlag(t *testing.T) {
+	old := pidfdOpen
+	defer func() { pidfdOpen = old }()
+	var flags []int
+	pidfdOpen = func(pid, f int) (int, error) {
+		flags = append(flags, f)
+		if f != 0 {
+			return -1, unix.EINVAL
+		}
+		return old(pid, f)
+	}
+	p := openPidfd(os.Getpid())
+	if p == nil {
+		t.Skip("pidfd_open not available")
+	}
+	defer p.close()
+	if len(flags) != 2 || flags[1] != 0 {
+		t.Errorf("pidfd_open flags = %v, want a retry without PIDFD_NONBLOCK", flags)
+	}
+	if err := p.signal(0); err != nil {
+		t.Errorf("signal(0) = %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build unix && !linux
+
+package ptyx
+
+import "syscall"
+
+type pidfd struct{ fd int }
+
+func openPidfd(int) *pidfd                   { return nil }
+func (p *pidfd) signal(syscall.Signal) error { return ErrUnsupported }
+func (p *pidfd) close()                      {}
+func awaitExit(*pidfd, int) bool             { return false }
@@ -0,0 +1,110 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var procDir = "/proc"
+
+type procStat struct {
+	pid, ppid, pgrp, sid int
+}
+
+func readProcStat(pid int) (procStat, error) {
+	b, err := os.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return procStat{}, err
+	}
+	return parseProcStat(string(b))
+}
+
+func parseProcStat(s string) (procStat, error) {
+	// The comm field is parenthesised and may itself contain spaces or ')'.
+	open, i := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if open < 0 || i < open {
+		return procStat{}, os.ErrInvalid
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(s[:open]))
+	if err != nil {
+		return procStat{}, err
+	}
+	f := strings.Fields(s[i+1:])
+	if len(f) < 4 {
+		return procStat{}, os.ErrInvalid
+	}
+	st := procStat{pid: pid}
+	for j, p := range []*int{&st.ppid, &st.pgrp, &st.sid} {
+		if *p, err = strconv.Atoi(f[j+1]); err != nil {
+			return procStat{}, err
+		}
+	}
+	return st, nil
+}
+
+// descendants returns every process that is a child of root, transitively,
+// or that still belongs to root's session after being re-parented. root must
+// not have been reaped, or its pid may belong to an unrelated process.
+func descendants(root int) []int {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil
+	}
+	children := map[int][]int{}
+	var out []int
+	seen := map[int]bool{root: true}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		st, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+		children[st.ppid] = append(children[st.ppid], pid)
+		if st.sid == root && !seen[pid] {
+			seen[pid] = true
+			out = append(out, pid)
+		}
+	}
+	queue := append([]int{root}, out...)
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, c := range children[pid] {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+				queue = append(queue, c)
+			}
+		}
+	}
+	return out
+}
+
+// foregroundProcess looks up the leader of the terminal's foreground process
+// group. Argv and Dir are left empty when /proc does not give them away.
+func foregroundProcess(fd int) (ForegroundProcess, error) {
+	pgrp, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+	if err != nil {
+		return ForegroundProcess{}, err
+	}
+	dir := filepath.Join(procDir, strconv.Itoa(pgrp))
+	comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+	if err != nil {
+		return ForegroundProcess{}, err
+	}
+	fp := ForegroundProcess{Pid: pgrp, Name: strings.TrimSuffix(string(comm), "\n")}
+	if b, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(b) > 0 {
+		fp.Argv = strings.Split(strings.TrimSuffix(string(b), "\x00"), "\x00")
+	}
+	fp.Dir, _ = os.Readlink(filepath.Join(dir, "cwd"))
+	return fp, nil
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestParseProcStat(t *testing.T) {
+	st, err := parseProcStat("42 (weird) name)) S 7 42 40 0 -1")
+	if err != nil {
+		t.Fatalf("parseProcStat() failed: %v", err)
+	}
+	if want := (procStat{pid: 42, ppid: 7, pgrp: 42, sid: 40}); st != want {
+		t.Errorf("parseProcStat() = %+v, want %+v", st, want)
+	}
+	for _, in := range []string{"", "42 (x", "x (y) S 1 2 3", "1 (y) S 1 2", "1 (y) S a 2 3", "42 x) S 1 2 3", "42 ) (x S 1 2 3"} {
+		if _, err := parseProcStat(in); err == nil {
+			t.Errorf("parseProcStat(%q) should fail", in)
+		}
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	dir := t.TempDir()
+	orig := procDir
+	procDir = dir
+	t.Cleanup(func() { procDir = orig })
+
+	write := func(pid, ppid, pgrp, sid int) {
+		p := filepath.Join(dir, strconv.Itoa(pid))
+		_ = os.MkdirAll(p, 0o755)
+		line := strconv.Itoa(pid) + " (p) S " + strconv.Itoa(ppid) + " " + strconv.Itoa(pgrp) + " " + strconv.Itoa(sid) + " 0"
+		if err := os.WriteFile(filepath.Join(p, "stat"), []byte(line), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(100, 1, 100, 100) // root
+	write(101, 100, 100, 100)
+	write(102, 101, 102, 102) // escaped into its own session
+	write(103, 102, 102, 102)
+	write(104, 1, 104, 100)   // orphaned but still in the root session
+	write(105, 100, 105, 105) // a child that set up its own session
+	write(200, 1, 200, 200)   // unrelated
+	_ = os.MkdirAll(filepath.Join(dir, "self"), 0o755)
+	_ = os.MkdirAll(filepath.Join(dir, "300"), 0o755)
+
+	got := descendants(100)
+	sort.Ints(got)
+	if want := []int{101, 102, 103, 104, 105}; !reflect.DeepEqual(got, want) {
+		t.Errorf("descendants() = %v, want %v", got, want)
+	}
+
+	procDir = filepath.Join(dir, "missing")
+	if got := descendants(100); got != nil {
+		t.Errorf("descendants() without /proc = %v, want nil", got)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build unix && !linux
+
+package ptyx
+
+func descendants(int) []int { return nil }
+
+func foregroundProcess(int) (ForegroundProcess, error) { return ForegroundProcess{}, ErrUnsupported }
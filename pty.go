@@ -0,0 +1,28 @@
+package ptyx
+
+import (
+	"errors"
+	"os"
+)
+
+// Pty is a bare pseudo-terminal pair for callers that manage the process on
+// the slave side themselves.
+type Pty struct {
+	master *os.File
+	slave  *os.File
+}
+
+func (p *Pty) Master() *os.File { return p.master }
+func (p *Pty) Slave() *os.File  { return p.slave }
+func (p *Pty) Name() string     { return p.slave.Name() }
+
+// Close closes both ends; either may already have been closed by the caller.
+func (p *Pty) Close() error {
+	var errs []error
+	for _, f := range []*os.File{p.slave, p.master} {
+		if err := f.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,13 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package ptyx
+
+func OpenPTY() (*Pty, error) {
+	m, s, err := openPTY(defaultPtmx)
+	if err != nil {
+		return nil, err
+	}
+	return &Pty{master: m, slave: s}, nil
+}
+
+func (p *Pty) Resize(cols, rows int) error { return setWinsize(int(p.master.Fd()), cols, rows) }
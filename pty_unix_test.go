@@ -0,0 +1,59 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package ptyx
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestOpenPTY_Public(t *testing.T) {
+	p, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY() failed: %v", err)
+	}
+	defer p.Close()
+
+	if !strings.HasPrefix(p.Name(), "/dev/") {
+		t.Errorf("Name() = %q, want a /dev path", p.Name())
+	}
+	if p.Slave().Name() != p.Name() {
+		t.Errorf("Slave().Name() = %q, want %q", p.Slave().Name(), p.Name())
+	}
+
+	if err := p.Resize(100, 30); err != nil {
+		t.Fatalf("Resize() failed: %v", err)
+	}
+	ws, err := unix.IoctlGetWinsize(int(p.Slave().Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		t.Fatalf("TIOCGWINSZ failed: %v", err)
+	}
+	if ws.Col != 100 || ws.Row != 30 {
+		t.Errorf("slave size = %dx%d, want 100x30", ws.Col, ws.Row)
+	}
+
+	if _, err := p.Slave().Write([]byte("ping\n")); err != nil {
+		t.Fatalf("slave write failed: %v", err)
+	}
+	buf := make([]byte, 64)
+	n, err := p.Master().Read(buf)
+	if err != nil || !strings.Contains(string(buf[:n]), "ping") {
+		t.Errorf("master read = %q, %v; want ping", buf[:n], err)
+	}
+}
+
+func TestPty_Close(t *testing.T) {
+	p, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY() failed: %v", err)
+	}
+	_ = p.Slave().Close()
+	if err := p.Close(); err != nil {
+		t.Errorf("Close() after closing the slave = %v, want nil", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("second Close() = %v, want nil", err)
+	}
+}
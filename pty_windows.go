@@ -0,0 +1,9 @@
+//go:build windows
+
+package ptyx
+
+// OpenPTY is not available on Windows, where ConPTY does not expose a slave
+// device; use Spawn instead.
+func OpenPTY() (*Pty, error) { return nil, ErrUnsupported }
+
+func (p *Pty) Resize(cols, rows int) error { return ErrUnsupported }
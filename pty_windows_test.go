@@ -0,0 +1,17 @@
+//go:build windows
+
+package ptyx
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOpenPTY_Unsupported(t *testing.T) {
+	if _, err := OpenPTY(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("OpenPTY() = %v, want ErrUnsupported", err)
+	}
+	if err := (&Pty{}).Resize(80, 24); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Resize() = %v, want ErrUnsupported", err)
+	}
+}
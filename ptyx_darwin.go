@@ -10,8 +10,13 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func openPTY() (pty, tty *os.File, err error) {
-	pty, err = os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+)
+
+func openPTY(ptmx string) (pty, tty *os.File, err error) {
+	pty, err = os.OpenFile(ptmx, os.O_RDWR, 0)
 	if err != nil {
 		return nil, nil, err
 	}
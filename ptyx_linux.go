@@ -5,6 +5,8 @@ package ptyx
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -16,8 +18,13 @@ var (
 	unixClose   = unix.Close
 )
 
-func openPTY() (*os.File, *os.File, error) {
-	masterFd, err := unixOpen("/dev/ptmx", unix.O_RDWR|unix.O_CLOEXEC, 0)
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
+
+func openPTY(ptmx string) (*os.File, *os.File, error) {
+	masterFd, err := unixOpen(ptmx, unix.O_RDWR|unix.O_CLOEXEC, 0)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -29,7 +36,7 @@ func openPTY() (*os.File, *os.File, error) {
 		_ = unixClose(masterFd)
 		return nil, nil, fmt.Errorf("ioctl(TIOCGPTN): %w", err)
 	}
-	slaveName := fmt.Sprintf("/dev/pts/%d", ptn)
+	slaveName := slavePath(ptmx, ptn)
 
 	var p int
 	_, _, errno = unixSyscall(unix.SYS_IOCTL, uintptr(masterFd), unix.TIOCSPTLCK, uintptr(unsafe.Pointer(&p)))
@@ -39,11 +46,25 @@ func openPTY() (*os.File, *os.File, error) {
 		return nil, nil, fmt.Errorf("ioctl(TIOCSPTLCK): %w", err)
 	}
 
-	slaveFd, err := unixOpen(slaveName, unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
-	if err != nil {
-		_ = unixClose(masterFd)
-		return nil, nil, err
+	// TIOCGPTPEER (Linux 4.13) opens the peer of this very master, so it
+	// works where the path would resolve in a different devpts instance.
+	r1, _, errno := unixSyscall(unix.SYS_IOCTL, uintptr(masterFd), unix.TIOCGPTPEER, uintptr(unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC))
+	slaveFd := int(r1)
+	if errno != 0 {
+		if slaveFd, err = unixOpen(slaveName, unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0); err != nil {
+			_ = unixClose(masterFd)
+			return nil, nil, err
+		}
 	}
 
-	return os.NewFile(uintptr(masterFd), "pty-master"), os.NewFile(uintptr(slaveFd), "pty-slave"), nil
+	return os.NewFile(uintptr(masterFd), ptmx), os.NewFile(uintptr(slaveFd), slaveName), nil
+}
+
+// slavePath names pty n of the devpts instance ptmx belongs to: /dev/ptmx
+// maps to /dev/pts, and a ptmx node inside a devpts mount to its siblings.
+func slavePath(ptmx string, n uint32) string {
+	if ptmx == defaultPtmx {
+		return fmt.Sprintf("/dev/pts/%d", n)
+	}
+	return filepath.Join(filepath.Dir(ptmx), strconv.FormatUint(uint64(n), 10))
 }
@@ -3,10 +3,15 @@
 package ptyx
 
 import (
+	"context"
 	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
@@ -19,7 +24,7 @@ func TestOpenPTY_ErrorPaths(t *testing.T) {
 		}
 		t.Cleanup(func() { unixOpen = originalUnixOpen })
 
-		_, _, err := openPTY()
+		_, _, err := openPTY(defaultPtmx)
 		if err == nil {
 			t.Fatal("openPTY should have failed but did not")
 		}
@@ -38,7 +43,7 @@ func TestOpenPTY_ErrorPaths(t *testing.T) {
 		}
 		t.Cleanup(func() { unixSyscall = originalUnixSyscall })
 
-		_, _, err := openPTY()
+		_, _, err := openPTY(defaultPtmx)
 		if err == nil {
 			t.Fatal("openPTY should have failed but did not")
 		}
@@ -57,7 +62,7 @@ func TestOpenPTY_ErrorPaths(t *testing.T) {
 		}
 		t.Cleanup(func() { unixSyscall = originalUnixSyscall })
 
-		_, _, err := openPTY()
+		_, _, err := openPTY(defaultPtmx)
 		if err == nil {
 			t.Fatal("openPTY should have failed but did not")
 		}
@@ -67,6 +72,14 @@ func TestOpenPTY_ErrorPaths(t *testing.T) {
 	})
 
 	t.Run("OpenSlaveError", func(t *testing.T) {
+		originalUnixSyscall := unixSyscall
+		unixSyscall = func(trap, a1, a2, a3 uintptr) (r1, r2 uintptr, err syscall.Errno) {
+			if a2 == unix.TIOCGPTPEER {
+				return 0, 0, syscall.ENOTTY
+			}
+			return originalUnixSyscall(trap, a1, a2, a3)
+		}
+		t.Cleanup(func() { unixSyscall = originalUnixSyscall })
 		originalUnixOpen := unixOpen
 		unixOpen = func(path string, mode int, perm uint32) (int, error) {
 			if strings.HasPrefix(path, "/dev/pts/") {
@@ -76,7 +89,7 @@ func TestOpenPTY_ErrorPaths(t *testing.T) {
 		}
 		t.Cleanup(func() { unixOpen = originalUnixOpen })
 
-		_, _, err := openPTY()
+		_, _, err := openPTY(defaultPtmx)
 		if err == nil {
 			t.Fatal("openPTY should have failed but did not")
 		}
@@ -85,3 +98,131 @@ func TestOpenPTY_ErrorPaths(t *testing.T) {
 		}
 	})
 }
+
+func TestOpenPTY_Peer(t *testing.T) {
+	originalUnixOpen := unixOpen
+	unixOpen = func(path string, mode int, perm uint32) (int, error) {
+		if strings.HasPrefix(path, "/dev/pts/") {
+			return -1, errors.New("slave opened by path")
+		}
+		return originalUnixOpen(path, mode, perm)
+	}
+	t.Cleanup(func() { unixOpen = originalUnixOpen })
+
+	m, s, err := openPTY(defaultPtmx)
+	if err != nil && strings.Contains(err.Error(), "by path") {
+		t.Skip("TIOCGPTPEER not supported by this kernel")
+	}
+	if err != nil {
+		t.Fatalf("openPTY() failed: %v", err)
+	}
+	defer m.Close()
+	defer s.Close()
+	if !strings.HasPrefix(s.Name(), "/dev/pts/") {
+		t.Errorf("slave name = %q, want /dev/pts/N", s.Name())
+	}
+	if _, err := unix.IoctlGetTermios(int(s.Fd()), unix.TCGETS); err != nil {
+		t.Errorf("slave is not a terminal: %v", err)
+	}
+}
+
+func TestSlavePath(t *testing.T) {
+	tests := []struct {
+		ptmx string
+		want string
+	}{
+		{"/dev/ptmx", "/dev/pts/3"},
+		{"/dev/pts/ptmx", "/dev/pts/3"},
+		{"/run/sandbox/pts/ptmx", "/run/sandbox/pts/3"},
+	}
+	for _, tt := range tests {
+		if got := slavePath(tt.ptmx, 3); got != tt.want {
+			t.Errorf("slavePath(%q) = %q, want %q", tt.ptmx, got, tt.want)
+		}
+	}
+}
+
+func TestSpawn_PtmxPath(t *testing.T) {
+	_, err := Spawn(context.Background(), SpawnOpts{Prog: "true", PtmxPath: filepath.Join(t.TempDir(), "ptmx")})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Spawn() with a missing ptmx = %v, want ErrNotExist", err)
+	}
+
+	s, err := Spawn(context.Background(), SpawnOpts{Prog: "true", PtmxPath: "/dev/ptmx"})
+	if err != nil {
+		t.Fatalf("Spawn() with an explicit ptmx failed: %v", err)
+	}
+	defer s.Close()
+	if err := waitTimeout(t, s, 3*time.Second); err != nil {
+		t.Errorf("Wait() = %v", err)
+	}
+}
+
+func TestUnixSession_Foreground(t *testing.T) {
+	orig := foregroundPollInterval
+	foregroundPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { foregroundPollInterval = orig })
+
+	s, err := Spawn(context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-i"}, Env: []string{"PS1=$ "}})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	defer s.Close()
+	go func() { _, _ = io.Copy(io.Discard, s.PtyReader()) }()
+
+	fs := s.(ForegroundSession)
+	fp, err := fs.Foreground()
+	if err != nil || fp.Pid != s.Pid() || fp.Name != "sh" {
+		t.Fatalf("Foreground() = %+v, %v; want the shell itself", fp, err)
+	}
+	changes := fs.OnForegroundChange()
+	_, _ = s.PtyWriter().Write([]byte("cd / && sleep 5\n"))
+	deadline := time.After(3 * time.Second)
+	for fp.Name != "sleep" {
+		select {
+		case fp = <-changes:
+		case <-deadline:
+			t.Fatalf("OnForegroundChange() never reported sleep, last %+v", fp)
+		}
+	}
+	if fp.Pid == s.Pid() || fp.Dir != "/" || strings.Join(fp.Argv, " ") != "sleep 5" {
+		t.Errorf("Foreground = %+v, want sleep 5 in / in its own process group", fp)
+	}
+
+	_ = s.Close()
+	select {
+	case _, ok := <-changes:
+		for ok {
+			_, ok = <-changes
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("OnForegroundChange() was not closed after the session ended")
+	}
+	_ = waitTimeout(t, s, 3*time.Second)
+	if _, err := fs.Foreground(); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("Foreground() = %v after exit, want os.ErrProcessDone", err)
+	}
+}
+
+func TestForegroundProcess_Errors(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := foregroundProcess(int(f.Fd())); err == nil {
+		t.Error("foregroundProcess() on a non-tty should fail")
+	}
+
+	p, err := OpenPTY()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	orig := procDir
+	procDir = t.TempDir()
+	t.Cleanup(func() { procDir = orig })
+	if _, err := foregroundProcess(int(p.Master().Fd())); err == nil {
+		t.Error("foregroundProcess() without a /proc entry should fail")
+	}
+}
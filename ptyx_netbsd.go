@@ -9,8 +9,13 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func openPTY() (*os.File, *os.File, error) {
-	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+)
+
+func openPTY(ptmx string) (*os.File, *os.File, error) {
+	master, err := os.OpenFile(ptmx, os.O_RDWR, 0)
 	if err != nil {
 		return nil, nil, err
 	}
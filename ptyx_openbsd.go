@@ -9,7 +9,13 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func openPTY() (*os.File, *os.File, error) {
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+)
+
+// OpenBSD has no ptmx clone device, so the ptmx path is not used.
+func openPTY(string) (*os.File, *os.File, error) {
 	for i := 0; i < 256; i++ {
 		masterPath := fmt.Sprintf("/dev/pty%c%x", 'p'+i/16, i%16)
 		master, err := os.OpenFile(masterPath, os.O_RDWR, 0)
@@ -5,23 +5,122 @@ package ptyx
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
 
+const defaultPtmx = "/dev/ptmx"
+
 type unixSession struct {
 	cmd    *exec.Cmd
 	master *os.File
+	reader io.Reader
+	events <-chan PacketEvent
+	pump   *outputPump
+	writer *ptyWriter
+
+	ctx             context.Context
+	cancelSig       os.Signal
+	killDelay       time.Duration
+	killDescendants bool
+	termOnce        sync.Once
+	forceKilled     atomic.Bool
+	killCalled      atomic.Bool
+	killedBy        atomic.Int32
+	done            chan struct{}
+	waitErr         error
+	started         time.Time
+	result          Result
+	running         atomic.Bool
+	closed          atomic.Bool
+	closing         chan struct{}
+	stopWatch       func() bool
+	echoOnce        sync.Once
+	echoCh          chan bool
+	fgOnce          sync.Once
+	fgCh            chan ForegroundProcess
+	stdinOnce       sync.Once
+	stdinErr        error
+
+	pidfd   *pidfd
+	init    *initPipe
+	sandbox *SandboxReport
+	reapMu  sync.RWMutex
+	reaped  bool
+
+	restoreTTY func()
+	ttyOnce    sync.Once
+	cgroup     *cgroup
 }
 
-func Spawn(ctx context.Context, opts SpawnOpts) (sess Session, err error) {
+func Spawn(ctx context.Context, opts SpawnOpts) (Session, error) {
 	if opts.Prog == "" {
 		return nil, errors.New("ptyx: empty program")
 	}
-	m, s, err := openPTY()
+	var cred *syscall.Credential
+	if opts.Credential != nil {
+		a, err := lookupAccount(*opts.Credential)
+		if err != nil {
+			return nil, err
+		}
+		cred, opts.Env = &a.cred, a.environ(opts.Env)
+	}
+	if opts.Isolation != nil || opts.Sandbox != nil {
+		return spawnIsolated(ctx, opts, cred)
+	}
+	cmd := exec.Command(opts.Prog, opts.Args...)
+	cmd.Env = opts.Env
+	if opts.Dir != "" {
+		cmd.Dir = opts.Dir
+	}
+	if opts.LoginShell {
+		cmd.Args[0] = loginArgv0(opts.Prog)
+	}
+	if cred != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+	}
+	us, err := startSession(ctx, cmd, opts, cred)
+	if err != nil {
+		return nil, err
+	}
+	us.startWaiter()
+	return us, nil
+}
+
+// SpawnCmd starts a caller-built command on a new pty. The slave becomes the
+// command's stdin and controlling terminal, and its stdout and stderr unless
+// those are already set. Setsid and Setctty are merged into any SysProcAttr
+// the caller provided; Setpgid and Foreground are cleared as they conflict.
+func SpawnCmd(ctx context.Context, cmd *exec.Cmd, cols, rows int) (Session, error) {
+	us, err := startSession(ctx, cmd, SpawnOpts{Cols: cols, Rows: rows}, nil)
+	if err != nil {
+		return nil, err
+	}
+	us.startWaiter()
+	return us, nil
+}
+
+// startSession runs cmd on a new pty. With owner set the slave is handed to
+// that user until Close. The session stays in StateStarting until the caller
+// has finished setting it up and calls startWaiter.
+func startSession(ctx context.Context, cmd *exec.Cmd, opts SpawnOpts, owner *syscall.Credential) (us *unixSession, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ptmx := opts.PtmxPath
+	if ptmx == "" {
+		ptmx = defaultPtmx
+	}
+	m, s, err := openPTY(ptmx)
 	if err != nil {
 		return nil, err
 	}
@@ -32,45 +131,461 @@ func Spawn(ctx context.Context, opts SpawnOpts) (sess Session, err error) {
 		}
 	}()
 
-	cmd := exec.CommandContext(ctx, opts.Prog, opts.Args...)
-	cmd.Env = opts.Env
-	if opts.Dir != "" {
-		cmd.Dir = opts.Dir
+	if opts.Termios != nil {
+		if err = setTermios(int(s.Fd()), *opts.Termios); err != nil {
+			return nil, fmt.Errorf("ptyx: termios: %w", err)
+		}
+	}
+
+	var restoreTTY func()
+	if owner != nil {
+		if restoreTTY, err = chownTTY(s, owner); err != nil {
+			return nil, fmt.Errorf("ptyx: tty owner: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				restoreTTY()
+			}
+		}()
+	}
+
+	var reader io.Reader = eofReader{m}
+	var events <-chan PacketEvent
+	if opts.PacketMode {
+		if err = enablePacketMode(int(m.Fd())); err != nil {
+			return nil, fmt.Errorf("ptyx: packet mode: %w", err)
+		}
+		pr := newPacketReader(eofReader{m})
+		reader, events = pr, pr.events
+	}
+
+	cmd.Stdin = s
+	if cmd.Stdout == nil {
+		cmd.Stdout = s
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = s
+	}
+	cmd.SysProcAttr = mergeSysProcAttr(cmd.SysProcAttr)
+
+	us = &unixSession{
+		cmd:             cmd,
+		ctx:             ctx,
+		master:          m,
+		reader:          reader,
+		events:          events,
+		cancelSig:       opts.CancelSignal,
+		killDelay:       opts.KillDelay,
+		killDescendants: opts.KillDescendants,
+		done:            make(chan struct{}),
+		closing:         make(chan struct{}),
+		restoreTTY:      restoreTTY,
+		writer:          &ptyWriter{f: m},
+	}
+	if us.killDelay == 0 {
+		us.killDelay = defaultKillDelay
 	}
-	cmd.Stdin, cmd.Stdout, cmd.Stderr = s, s, s
-	cmd.SysProcAttr = newSysProcAttr()
 
 	if opts.Cols > 0 && opts.Rows > 0 {
 		_ = setWinsize(int(m.Fd()), opts.Cols, opts.Rows)
 	}
 
-	if err = cmd.Start(); err != nil {
+	us.cgroup = attachCgroup(cmd, opts.Cgroup)
+	if cmd, err = us.cgroup.start(ctx, cmd); err != nil {
 		return nil, err
 	}
+	us.cmd = cmd
+	us.started = time.Now()
 	_ = s.Close()
+	us.pidfd = openPidfd(cmd.Process.Pid)
+	if opts.DrainOutput {
+		us.pump = newOutputPump(reader)
+		us.reader = us.pump
+	}
+
+	us.stopWatch = context.AfterFunc(ctx, func() {
+		us.setKilledBy(KilledByContext)
+		_ = us.terminate()
+	})
 
-	return &unixSession{cmd: cmd, master: m}, nil
+	return us, nil
+}
+
+func (s *unixSession) PtyReader() io.Reader             { return s.reader }
+func (s *unixSession) PacketEvents() <-chan PacketEvent { return s.events }
+func (s *unixSession) PtyWriter() io.Writer             { return s.writer }
+func (s *unixSession) Resize(cols, rows int) error      { return setWinsize(int(s.master.Fd()), cols, rows) }
+
+// startWaiter reaps the child in the background, so Wait, Done and
+// ExitStatus can be used by any number of callers.
+func (s *unixSession) startWaiter() {
+	s.running.Store(true)
+	go func() {
+		s.waitErr = s.reap()
+		s.result.ExitCode = exitCode(s.waitErr)
+		s.stopWatch()
+		close(s.done)
+	}()
 }
 
-func (s *unixSession) PtyReader() io.Reader { return s.master }
-func (s *unixSession) PtyWriter() io.Writer { return s.master }
-func (s *unixSession) Resize(cols, rows int) error { return setWinsize(int(s.master.Fd()), cols, rows) }
 func (s *unixSession) Wait() error {
+	<-s.done
+	return s.waitErr
+}
+
+func (s *unixSession) Done() <-chan struct{} { return s.done }
+
+func (s *unixSession) WaitContext(ctx context.Context) error { return waitContext(ctx, s) }
+
+func (s *unixSession) ExitStatus() (int, bool) {
+	if !isDone(s.done) {
+		return 0, false
+	}
+	return s.result.ExitCode, true
+}
+
+func (s *unixSession) Result() (Result, bool) {
+	if !isDone(s.done) {
+		return Result{}, false
+	}
+	return s.result, true
+}
+
+func (s *unixSession) State() SessionState {
+	switch {
+	case s.closed.Load():
+		return StateClosed
+	case isDone(s.done):
+		return StateExited
+	case s.running.Load():
+		return StateRunning
+	}
+	return StateStarting
+}
+
+func (s *unixSession) reap() error {
+	if awaitExit(s.pidfd, s.cmd.Process.Pid) {
+		s.markReaped()
+	}
 	err := s.cmd.Wait()
+	s.markReaped()
+	s.cgroup.removeIfEmpty()
+	s.result = processResult(s.cmd.ProcessState, s.started, time.Now())
+	s.pump.drain()
 	if exitErr, ok := err.(*exec.ExitError); ok {
-		return &ExitError{
-			ExitCode:   exitErr.ExitCode(),
-			waitStatus: exitErr.Sys(),
+		ws, _ := exitErr.Sys().(syscall.WaitStatus)
+		if st, ok := s.init.status(); ok {
+			ws = st
+		}
+		ee := &ExitError{
+			ExitCode:   ws.ExitStatus(),
+			KilledBy:   KillCause(s.killedBy.Load()),
+			waitStatus: ws,
+		}
+		if ws.Signaled() {
+			ee.Signaled = true
+			ee.Signal = portableSignal(ws.Signal())
+			ee.CoreDumped = ws.CoreDump()
+			ee.ForceKilled = ws.Signal() == syscall.SIGKILL && s.forceKilled.Load()
 		}
+		return ee
+	}
+	return err
+}
+
+// markReaped stops further signals; once the child is reaped its pid and
+// process group id are free to be reused by an unrelated process.
+func (s *unixSession) markReaped() {
+	s.reapMu.Lock()
+	s.reaped = true
+	s.reapMu.Unlock()
+}
+
+// whileAlive runs fn only if the child has not been reaped, holding off
+// Wait until fn returns.
+func (s *unixSession) whileAlive(fn func() error) error {
+	s.reapMu.RLock()
+	defer s.reapMu.RUnlock()
+	if s.reaped {
+		return os.ErrProcessDone
+	}
+	return fn()
+}
+
+func (s *unixSession) signalLeader(sig syscall.Signal) error {
+	if s.pidfd != nil {
+		if err := s.pidfd.signal(sig); !errors.Is(err, os.ErrClosed) {
+			return err
+		}
+	}
+	return s.cmd.Process.Signal(sig)
+}
+
+func (s *unixSession) SandboxReport() *SandboxReport { return s.sandbox }
+
+func (s *unixSession) CgroupStats() (CgroupStats, error) { return s.cgroup.stats() }
+
+func (s *unixSession) ExitFd() (uintptr, error) {
+	if s.pidfd == nil {
+		return 0, ErrUnsupported
+	}
+	return uintptr(s.pidfd.fd), nil
+}
+
+// Kill sends CancelSignal and escalates after KillDelay; calling it again
+// during that grace period kills the group outright.
+func (s *unixSession) Kill() error {
+	s.setKilledBy(KilledByKill)
+	if s.killCalled.Swap(true) && s.cancelSig != nil && s.cancelSig != os.Kill {
+		s.forceKilled.Store(true)
+		return s.signalGroup(syscall.SIGKILL)
+	}
+	return s.terminate()
+}
+
+// setKilledBy records the first reason ptyx stopped the process. Once the
+// spawn context is done, that is the reason, whichever call got here first.
+func (s *unixSession) setKilledBy(c KillCause) {
+	if s.ctx.Err() != nil {
+		c = contextKillCause(s.ctx)
+	}
+	select {
+	case <-s.done:
+	default:
+		s.killedBy.CompareAndSwap(int32(KilledByNone), int32(c))
+	}
+}
+
+func (s *unixSession) terminate() error {
+	if s.cancelSig == nil || s.cancelSig == os.Kill {
+		return s.signalGroup(syscall.SIGKILL)
 	}
+	var err error
+	s.termOnce.Do(func() {
+		if sig, ok := s.cancelSig.(syscall.Signal); ok {
+			err = s.signalGroup(sig)
+		} else {
+			err = s.cmd.Process.Signal(s.cancelSig)
+		}
+		if s.killDelay < 0 {
+			return
+		}
+		time.AfterFunc(s.killDelay, func() {
+			select {
+			case <-s.done:
+			default:
+				s.forceKilled.Store(true)
+				_ = s.signalGroup(syscall.SIGKILL)
+			}
+		})
+	})
 	return err
 }
-func (s *unixSession) Kill() error { return s.cmd.Process.Kill() }
-func (s *unixSession) Close() error { return s.master.Close() }
+
+func (s *unixSession) Close() error {
+	if s.closed.CompareAndSwap(false, true) {
+		close(s.closing)
+	}
+	s.setKilledBy(KilledByClose)
+	_ = s.signalGroup(syscall.SIGKILL)
+	if s.pidfd != nil {
+		s.pidfd.close()
+	}
+	s.init.close()
+	s.cgroup.remove()
+	if s.restoreTTY != nil {
+		s.ttyOnce.Do(s.restoreTTY)
+	}
+	s.pump.stop()
+	return s.master.Close()
+}
+
+// signalGroup signals the session's process group, which Setsid makes the
+// same as the child's pid, so background jobs go down with the leader. Once
+// the leader has been reaped, the group is still signalled while a job left
+// in it exists: its id cannot be reused until the last member exits.
+func (s *unixSession) signalGroup(sig syscall.Signal) error {
+	err := s.whileAlive(func() error {
+		pid := s.cmd.Process.Pid
+		var extra []int
+		if s.killDescendants {
+			extra = descendants(pid)
+		}
+		err := syscall.Kill(-pid, sig)
+		if err == syscall.ESRCH {
+			err = s.signalLeader(sig)
+		}
+		for _, p := range extra {
+			_ = syscall.Kill(p, sig)
+		}
+		return err
+	})
+	if err != os.ErrProcessDone {
+		return err
+	}
+	pgid := s.cmd.Process.Pid
+	if syscall.Kill(-pgid, 0) != nil {
+		return err
+	}
+	return syscall.Kill(-pgid, sig)
+}
+
+// withMaster runs fn on the pty master's descriptor. The descriptor stays
+// open while fn runs and fails once Close has released it, so a number
+// reused by another file is never touched.
+func (s *unixSession) withMaster(fn func(fd int) error) error {
+	rc, err := s.master.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	if err := rc.Control(func(fd uintptr) { ferr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return ferr
+}
+
 func (s *unixSession) Pid() int { return s.cmd.Process.Pid }
 
+// CloseStdin ends the child's input the way a terminal does, by typing
+// VEOF: once at the start of a line, or twice after a partial one, whose
+// first VEOF only hands the line over. The master stays open so output can
+// still be read, and further writes fail.
 func (s *unixSession) CloseStdin() error {
-	return s.master.Close()
+	s.stdinOnce.Do(func() {
+		t, err := unix.IoctlGetTermios(int(s.master.Fd()), ioctlGetTermios)
+		if err != nil {
+			s.stdinErr = err
+			return
+		}
+		c := t.Cc[unix.VEOF]
+		switch {
+		case t.Lflag&unix.ICANON == 0:
+			s.stdinErr = errors.New("ptyx: terminal is not in canonical mode, so input cannot be ended")
+			return
+		case c == 0 || c == 0xff:
+			s.stdinErr = errors.New("ptyx: VEOF is disabled on this terminal")
+			return
+		}
+		eof := []byte{c}
+		if s.writer.midLine.Load() {
+			eof = append(eof, c)
+		}
+		s.writer.closed.Store(true)
+		_, s.stdinErr = s.master.Write(eof)
+	})
+	return s.stdinErr
+}
+
+func (s *unixSession) Signal(sig Signal) error {
+	native, ok := nativeSignal(sig)
+	if !ok {
+		return ErrUnsupported
+	}
+	return s.whileAlive(func() error { return s.signalLeader(native) })
+}
+
+func (s *unixSession) SignalForeground(sig Signal) error {
+	native, ok := nativeSignal(sig)
+	if !ok {
+		return ErrUnsupported
+	}
+	return s.whileAlive(func() error {
+		pgrp, err := unix.IoctlGetInt(int(s.master.Fd()), unix.TIOCGPGRP)
+		if err != nil || pgrp <= 0 {
+			pgrp = s.cmd.Process.Pid
+		}
+		return syscall.Kill(-pgrp, native)
+	})
+}
+
+func (s *unixSession) Foreground() (ForegroundProcess, error) {
+	var fp ForegroundProcess
+	err := s.whileAlive(func() (err error) {
+		return s.withMaster(func(fd int) (err error) {
+			fp, err = foregroundProcess(fd)
+			return err
+		})
+	})
+	return fp, err
+}
+
+func (s *unixSession) OnForegroundChange() <-chan ForegroundProcess {
+	if _, err := foregroundProcess(int(s.master.Fd())); errors.Is(err, ErrUnsupported) {
+		return nil
+	}
+	s.fgOnce.Do(func() {
+		s.fgCh = make(chan ForegroundProcess, 1)
+		last, _ := s.Foreground()
+		go s.watchForeground(last)
+	})
+	return s.fgCh
+}
+
+// watchForeground polls like watchEcho; the kernel has no notification for
+// a change of foreground process group.
+func (s *unixSession) watchForeground(last ForegroundProcess) {
+	defer close(s.fgCh)
+	tick := time.NewTicker(foregroundPollInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.closing:
+			return
+		case <-tick.C:
+		}
+		fp, err := s.Foreground()
+		if err != nil || fp.equal(last) {
+			continue
+		}
+		last = fp
+		select {
+		case <-s.fgCh:
+		default:
+		}
+		s.fgCh <- fp
+	}
+}
+
+func (s *unixSession) Interrupt(mode InterruptMode) error {
+	if mode == InterruptBySignal {
+		return s.SignalForeground(SIGINT)
+	}
+	c := byte(0x03)
+	if t, err := unix.IoctlGetTermios(int(s.master.Fd()), ioctlGetTermios); err == nil {
+		c = t.Cc[unix.VINTR]
+		if c == 0 || c == 0xff {
+			return errors.New("ptyx: VINTR is disabled on this terminal")
+		}
+	}
+	_, err := s.master.Write([]byte{c})
+	return err
+}
+
+func nativeSignal(sig Signal) (syscall.Signal, bool) {
+	if _, ok := signalNames[sig]; !ok {
+		return 0, false
+	}
+	n := unix.SignalNum(sig.String())
+	return n, n != 0
+}
+
+// portableSignal maps a native signal to its portable value by name. One
+// without a portable name keeps its number on Linux, whose numbering the
+// portable values follow, and is offset by nativeSignalBase elsewhere.
+func portableSignal(sig syscall.Signal) Signal {
+	name := unix.SignalName(sig)
+	for s, n := range signalNames {
+		if n == name {
+			return s
+		}
+	}
+	if runtime.GOOS == "linux" || sig == 0 {
+		return Signal(sig)
+	}
+	return nativeSignalBase + Signal(sig)
 }
 
 func setWinsize(fd int, cols, rows int) error {
@@ -78,6 +593,37 @@ func setWinsize(fd int, cols, rows int) error {
 	return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, ws)
 }
 
+// ptyWriter is the child's input. It notes whether the last write ended a
+// line for CloseStdin, and refuses writes once input has been closed.
+type ptyWriter struct {
+	f       *os.File
+	midLine atomic.Bool
+	closed  atomic.Bool
+}
+
+func (w *ptyWriter) Write(p []byte) (int, error) {
+	if w.closed.Load() {
+		return 0, os.ErrClosed
+	}
+	n, err := w.f.Write(p)
+	if n > 0 {
+		w.midLine.Store(p[n-1] != '\n' && p[n-1] != '\r')
+	}
+	return n, err
+}
+
+// eofReader reports the EIO Linux returns once every slave descriptor is
+// closed and the output is drained as io.EOF.
+type eofReader struct{ r io.Reader }
+
+func (r eofReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+	return n, err
+}
+
 func clen(b []byte) int {
 	for i := 0; i < len(b); i++ {
 		if b[i] == 0 {
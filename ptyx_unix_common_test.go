@@ -11,10 +11,13 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
 	"syscall"
 	"testing"
 	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 func TestHelperProcess(t *testing.T) {
@@ -34,7 +37,7 @@ func TestHelperProcess(t *testing.T) {
 }
 
 func TestOpenPTY(t *testing.T) {
-	master, slave, err := openPTY()
+	master, slave, err := openPTY(defaultPtmx)
 	if err != nil {
 		t.Fatalf("openPTY() failed: %v", err)
 	}
@@ -287,3 +290,626 @@ func isPTYEOF(err error) bool {
 	var errno syscall.Errno
 	return errors.As(err, &errno) && (errno == syscall.EIO || errno == 0)
 }
+
+func spawnReady(t *testing.T, ctx context.Context, opts SpawnOpts) Session {
+	t.Helper()
+	s, err := Spawn(ctx, opts)
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	br := bufio.NewReader(s.PtyReader())
+	for {
+		line, err := br.ReadString('\n')
+		if strings.Contains(line, "ready") {
+			break
+		}
+		if err != nil {
+			t.Fatalf("child did not become ready: %v", err)
+		}
+	}
+	go func() { _, _ = io.Copy(io.Discard, br) }()
+	return s
+}
+
+func waitTimeout(t *testing.T, s Session, d time.Duration) error {
+	t.Helper()
+	ch := make(chan error, 1)
+	go func() { ch <- s.Wait() }()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(d):
+		_ = s.Close()
+		t.Fatal("Wait() timed out")
+		return nil
+	}
+}
+
+func TestUnixSession_CancelSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := spawnReady(t, ctx, SpawnOpts{
+		Prog:         "sh",
+		Args:         []string{"-c", `trap 'exit 3' TERM; echo ready; while :; do sleep 0.05; done`},
+		CancelSignal: syscall.SIGTERM,
+	})
+
+	cancel()
+	var exitErr *ExitError
+	if err := waitTimeout(t, s, 3*time.Second); !errors.As(err, &exitErr) || exitErr.ExitCode != 3 || exitErr.ForceKilled {
+		t.Fatalf("Wait() = %v, want graceful exit with status 3", err)
+	}
+}
+
+func TestUnixSession_KillEscalates(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{
+		Prog:         "sh",
+		Args:         []string{"-c", `trap '' TERM; echo ready; while :; do sleep 0.05; done`},
+		CancelSignal: syscall.SIGTERM,
+		KillDelay:    100 * time.Millisecond,
+	})
+
+	start := time.Now()
+	if err := s.Kill(); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("Kill() blocked for %v", d)
+	}
+	if err := s.Kill(); err != nil {
+		t.Errorf("second Kill() failed: %v", err)
+	}
+
+	var exitErr *ExitError
+	if err := waitTimeout(t, s, 3*time.Second); !errors.As(err, &exitErr) || !exitErr.ForceKilled {
+		t.Fatalf("Wait() = %v, want a force-killed ExitError", err)
+	}
+}
+
+func TestUnixSession_SecondKillSkipsGracePeriod(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{
+		Prog:         "sh",
+		Args:         []string{"-c", `trap '' TERM; echo ready; while :; do sleep 0.05; done`},
+		CancelSignal: syscall.SIGTERM,
+		KillDelay:    time.Minute,
+	})
+	defer s.Close()
+
+	_ = s.Kill()
+	if err := s.Kill(); err != nil {
+		t.Fatalf("second Kill() failed: %v", err)
+	}
+	var exitErr *ExitError
+	if err := waitTimeout(t, s, 3*time.Second); !errors.As(err, &exitErr) || !exitErr.ForceKilled || exitErr.Signal != SIGKILL {
+		t.Fatalf("Wait() = %v, want SIGKILL from the second Kill", err)
+	}
+}
+
+func TestRun_CancelSignal(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err := Run(ctx, SpawnOpts{
+		Prog:         "sh",
+		Args:         []string{"-c", `trap 'exit 5' HUP; while :; do sleep 0.05; done`},
+		CancelSignal: syscall.SIGHUP,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() = %v, want context.DeadlineExceeded", err)
+	}
+	var ctxErr *ContextError
+	if !errors.As(err, &ctxErr) || ctxErr.Exit == nil || ctxErr.Exit.ExitCode != 5 {
+		t.Errorf("Run() = %v, want the graceful exit status 5", err)
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		t.Errorf("Run() = %v, a cancelled Run must not match *ExitError", err)
+	}
+}
+
+func TestRun_KilledByTimeout(t *testing.T) {
+	for _, sig := range []os.Signal{nil, syscall.SIGTERM} {
+		t.Run(fmt.Sprint(sig), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := Run(ctx, SpawnOpts{
+				Prog:         "sh",
+				Args:         []string{"-c", "while :; do sleep 0.05; done"},
+				CancelSignal: sig,
+			})
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Run() = %v, want context.DeadlineExceeded", err)
+			}
+			var ctxErr *ContextError
+			if !errors.As(err, &ctxErr) || ctxErr.Exit == nil || ctxErr.Exit.KilledBy != KilledByTimeout {
+				t.Errorf("Run() = %v, want a ContextError with KilledBy timeout", err)
+			}
+		})
+	}
+}
+
+func processGone(pid int) bool {
+	if err := syscall.Kill(pid, 0); err == syscall.ESRCH {
+		return true
+	}
+	// Orphans are re-parented to an init that may not reap them promptly;
+	// a zombie no longer runs, so it counts as gone.
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return syscall.Kill(pid, 0) == syscall.ESRCH
+	}
+	f := strings.Fields(string(b[bytes.LastIndexByte(b, ')')+1:]))
+	return len(f) > 0 && f[0] == "Z"
+}
+
+func waitGone(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !processGone(pid) {
+		if time.Now().After(deadline) {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("process %d survived", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func spawnWithBackgroundJob(t *testing.T, launcher string, opts SpawnOpts) (Session, int) {
+	t.Helper()
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("requires /proc")
+	}
+	opts.Prog = "sh"
+	opts.Args = []string{"-c", launcher + ` sh -c 'echo "bg=$$"; exec sleep 1000' & echo ready; wait`}
+	s, err := Spawn(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	br := bufio.NewReader(s.PtyReader())
+	bg, ready := 0, false
+	for bg == 0 || !ready {
+		line, err := br.ReadString('\n')
+		if _, serr := fmt.Sscanf(strings.TrimSpace(line), "bg=%d", &bg); serr != nil && strings.Contains(line, "ready") {
+			ready = true
+		}
+		if err != nil {
+			t.Fatalf("child did not become ready: %v", err)
+		}
+	}
+	go func() { _, _ = io.Copy(io.Discard, br) }()
+	return s, bg
+}
+
+func TestUnixSession_KillProcessGroup(t *testing.T) {
+	s, bg := spawnWithBackgroundJob(t, "", SpawnOpts{})
+	defer s.Close()
+
+	if err := s.Kill(); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+	_ = waitTimeout(t, s, 3*time.Second)
+	waitGone(t, bg)
+}
+
+func TestUnixSession_CloseProcessGroup(t *testing.T) {
+	s, bg := spawnWithBackgroundJob(t, "", SpawnOpts{})
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	_ = waitTimeout(t, s, 3*time.Second)
+	waitGone(t, bg)
+}
+
+// TestUnixSession_CloseKillsGroupAfterLeaderExit checks that a background
+// job that ignored the hangup outlives the leader, and that Close still
+// reaches it once the leader has been reaped.
+func TestUnixSession_CloseKillsGroupAfterLeaderExit(t *testing.T) {
+	s, err := Spawn(context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c",
+		`(trap "" HUP; exec sleep 1000 </dev/null >/dev/null 2>&1) & echo "bg=$!"; sleep 0.3`}})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	out, _ := io.ReadAll(s.PtyReader())
+	var bg int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(out)), "bg=%d", &bg); err != nil {
+		_ = s.Close()
+		t.Fatalf("no background pid in %q", out)
+	}
+	_ = waitTimeout(t, s, 3*time.Second)
+	if processGone(bg) {
+		_ = s.Close()
+		t.Fatalf("background job %d exited with the leader", bg)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	waitGone(t, bg)
+}
+
+func TestUnixSession_KillDescendants(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("descendant tracking uses /proc")
+	}
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid not available")
+	}
+	s, bg := spawnWithBackgroundJob(t, "setsid", SpawnOpts{KillDescendants: true})
+	defer s.Close()
+
+	if err := s.Kill(); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+	_ = waitTimeout(t, s, 3*time.Second)
+	waitGone(t, bg)
+}
+
+func TestUnixSession_Signal(t *testing.T) {
+	trapScript := `trap 'exit 7' INT; trap 'exit 8' USR1; trap 'exit 9' TERM; echo ready; while :; do sleep 0.05; done`
+	tests := []struct {
+		name string
+		send func(Session) error
+		want int
+	}{
+		{"Signal", func(s Session) error { return s.Signal(SIGTERM) }, 9},
+		{"SignalForeground", func(s Session) error { return s.SignalForeground(SIGUSR1) }, 8},
+		{"InterruptBySignal", func(s Session) error { return s.Interrupt(InterruptBySignal) }, 7},
+		{"InterruptByChar", func(s Session) error { return s.Interrupt(InterruptByChar) }, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", trapScript}})
+			if err := tt.send(s); err != nil {
+				t.Fatalf("send failed: %v", err)
+			}
+			var exitErr *ExitError
+			if err := waitTimeout(t, s, 3*time.Second); !errors.As(err, &exitErr) || exitErr.ExitCode != tt.want {
+				t.Fatalf("Wait() = %v, want exit status %d", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnixSession_SignalUnsupported(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo ready; sleep 1"}})
+	defer s.Kill()
+	if err := s.Signal(Signal(64)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Signal(64) = %v, want ErrUnsupported", err)
+	}
+	if err := s.SignalForeground(Signal(64)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("SignalForeground(64) = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestUnixSession_ExitErrorCause(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		ctx     func() (context.Context, context.CancelFunc)
+		stop    func(Session, context.CancelFunc)
+		wantSig Signal
+		want    KillCause
+	}{
+		{
+			name:    "Kill",
+			stop:    func(s Session, _ context.CancelFunc) { _ = s.Kill() },
+			wantSig: SIGKILL,
+			want:    KilledByKill,
+		},
+		{
+			name:    "Close",
+			stop:    func(s Session, _ context.CancelFunc) { _ = s.Close() },
+			wantSig: SIGKILL,
+			want:    KilledByClose,
+		},
+		{
+			name:    "Cancel",
+			stop:    func(_ Session, cancel context.CancelFunc) { cancel() },
+			wantSig: SIGKILL,
+			want:    KilledByContext,
+		},
+		{
+			name: "Timeout",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 100*time.Millisecond)
+			},
+			stop:    func(Session, context.CancelFunc) {},
+			wantSig: SIGKILL,
+			want:    KilledByTimeout,
+		},
+		{
+			name:    "Self",
+			script:  "echo ready; kill -TERM $$",
+			stop:    func(Session, context.CancelFunc) {},
+			wantSig: SIGTERM,
+			want:    KilledByNone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.ctx != nil {
+				ctx, cancel = tt.ctx()
+			}
+			defer cancel()
+			script := tt.script
+			if script == "" {
+				script = "echo ready; while :; do sleep 0.05; done"
+			}
+			s := spawnReady(t, ctx, SpawnOpts{Prog: "sh", Args: []string{"-c", script}})
+			tt.stop(s, cancel)
+
+			var exitErr *ExitError
+			if err := waitTimeout(t, s, 3*time.Second); !errors.As(err, &exitErr) {
+				t.Fatalf("Wait() = %v, want *ExitError", err)
+			}
+			if !exitErr.Signaled || exitErr.Signal != tt.wantSig || exitErr.KilledBy != tt.want {
+				t.Errorf("ExitError = %+v, want signal %v killed by %v", exitErr, tt.wantSig, tt.want)
+			}
+		})
+	}
+}
+
+func TestPortableSignal(t *testing.T) {
+	if got := portableSignal(syscall.SIGTERM); got != SIGTERM {
+		t.Errorf("portableSignal(SIGTERM) = %v", got)
+	}
+	if got := portableSignal(syscall.Signal(0)); got != Signal(0) {
+		t.Errorf("portableSignal(0) = %v", got)
+	}
+	// Crash signals are numbered differently across platforms but keep
+	// their portable names.
+	for native, want := range map[syscall.Signal]Signal{
+		syscall.SIGSEGV: SIGSEGV, syscall.SIGBUS: SIGBUS, syscall.SIGSYS: SIGSYS,
+		syscall.SIGABRT: SIGABRT, syscall.SIGPIPE: SIGPIPE, syscall.SIGUSR1: SIGUSR1,
+	} {
+		if got := portableSignal(native); got != want {
+			t.Errorf("portableSignal(%d) = %v, want %v", int(native), got, want)
+		}
+		if n, ok := nativeSignal(want); !ok || n != native {
+			t.Errorf("nativeSignal(%v) = %d, %v; want %d", want, int(n), ok, int(native))
+		}
+	}
+	unnamed := syscall.Signal(40)
+	if unix.SignalName(unnamed) == "" {
+		want := nativeSignalBase + 40
+		if runtime.GOOS == "linux" {
+			want = 40
+		}
+		if got := portableSignal(unnamed); got != want {
+			t.Errorf("portableSignal(40) = %d, want %d", int(got), int(want))
+		}
+	}
+}
+
+func TestSpawnCmd(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", `[ -t 0 ] && echo "tty $PTYX_VAR"; echo extra >&3; echo oops >&2`)
+	cmd.Env = append(os.Environ(), "PTYX_VAR=set")
+	cmd.ExtraFiles = []*os.File{w}
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	s, err := SpawnCmd(context.Background(), cmd, 90, 20)
+	if err != nil {
+		t.Fatalf("SpawnCmd() failed: %v", err)
+	}
+	defer s.Close()
+	_ = w.Close()
+
+	out, _ := readPTYOneLine(s.PtyReader())
+	if !strings.Contains(out, "tty set") {
+		t.Errorf("pty output = %q, want the child to see a tty and its env", out)
+	}
+	if err := waitTimeout(t, s, 3*time.Second); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	extra, _ := io.ReadAll(r)
+	if string(extra) != "extra\n" {
+		t.Errorf("ExtraFiles output = %q, want %q", extra, "extra\n")
+	}
+	if stderr.String() != "oops\n" {
+		t.Errorf("stderr = %q, want the caller's writer to be kept", stderr.String())
+	}
+	if !cmd.SysProcAttr.Setsid || !cmd.SysProcAttr.Setctty || cmd.SysProcAttr.Setpgid {
+		t.Errorf("SysProcAttr = %+v, want Setsid/Setctty merged and Setpgid cleared", cmd.SysProcAttr)
+	}
+}
+
+func TestSpawnCmd_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SpawnCmd(ctx, exec.Command("true"), 0, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("SpawnCmd() = %v, want context.Canceled", err)
+	}
+}
+
+func TestUnixSession_ReadEndsWithEOF(t *testing.T) {
+	s, err := Spawn(context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo hi"}})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	defer s.Close()
+	b, err := io.ReadAll(s.PtyReader())
+	if err != nil || !strings.Contains(string(b), "hi") {
+		t.Errorf("ReadAll() = %q, %v; want the output and a clean io.EOF", b, err)
+	}
+	_ = waitTimeout(t, s, 3*time.Second)
+}
+
+func TestEOFReader(t *testing.T) {
+	r := eofReader{&errAfterReader{strings.NewReader("x"), &os.PathError{Op: "read", Path: "/dev/ptmx", Err: syscall.EIO}}}
+	if b, err := io.ReadAll(r); string(b) != "x" || err != nil {
+		t.Errorf("ReadAll() = %q, %v; want EIO reported as the end", b, err)
+	}
+	boom := errors.New("boom")
+	if _, err := (eofReader{&errAfterReader{strings.NewReader(""), boom}}).Read(make([]byte, 1)); err != boom {
+		t.Errorf("Read() = %v, want other errors unchanged", err)
+	}
+}
+
+// TestUnixSession_DrainOutputStress closes each session as soon as Wait
+// returns, before reading anything, and expects every byte to survive.
+func TestUnixSession_DrainOutputStress(t *testing.T) {
+	const size = 256 * 1024
+	raw := RawTermios()
+	for i := 0; i < 8; i++ {
+		s, err := Spawn(context.Background(), SpawnOpts{
+			Prog:        "sh",
+			Args:        []string{"-c", fmt.Sprintf("head -c %d /dev/zero | tr '\\0' x", size)},
+			Termios:     &raw,
+			DrainOutput: true,
+		})
+		if err != nil {
+			t.Fatalf("Spawn failed: %v", err)
+		}
+		if err := waitTimeout(t, s, 5*time.Second); err != nil {
+			t.Fatalf("Wait() = %v", err)
+		}
+		_ = s.Close()
+		n, err := io.Copy(io.Discard, s.PtyReader())
+		if err != nil || n != size {
+			t.Fatalf("run %d: read %d bytes, %v; want %d", i, n, err, size)
+		}
+	}
+}
+
+func TestUnixSession_DrainOutputBackgroundJob(t *testing.T) {
+	old := drainTimeout
+	drainTimeout = 100 * time.Millisecond
+	defer func() { drainTimeout = old }()
+
+	s, err := Spawn(context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "sleep 10 & echo done"}, DrainOutput: true})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	if err := waitTimeout(t, s, 3*time.Second); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	_ = s.Close()
+	if b, err := io.ReadAll(s.PtyReader()); err != nil || !strings.Contains(string(b), "done") {
+		t.Errorf("ReadAll() = %q, %v", b, err)
+	}
+}
+
+func closeStdinOutput(t *testing.T, opts SpawnOpts, input string) (string, error) {
+	t.Helper()
+	s, err := Spawn(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	br := bufio.NewReader(s.PtyReader())
+	if line, err := br.ReadString('\n'); !strings.Contains(line, "ready") {
+		t.Fatalf("child did not become ready: %q, %v", line, err)
+	}
+	if _, err := s.PtyWriter().Write([]byte(input)); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if err := s.CloseStdin(); err != nil {
+		return "", err
+	}
+	if _, err := s.PtyWriter().Write([]byte("more\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write() after CloseStdin = %v, want os.ErrClosed", err)
+	}
+	b, _ := io.ReadAll(br)
+	if err := waitTimeout(t, s, 3*time.Second); err != nil {
+		t.Errorf("Wait() = %v", err)
+	}
+	return strings.ReplaceAll(string(b), "\r\n", "\n"), nil
+}
+
+func TestUnixSession_CloseStdin(t *testing.T) {
+	const script = `stty -echo; echo ready; wc -c | tr -d ' '; echo end`
+	opts := SpawnOpts{Prog: "sh", Args: []string{"-c", script}}
+	for _, tt := range []struct{ name, input, want string }{
+		{"LineBoundary", "hello\n", "6\nend\n"},
+		{"MidLine", "partial", "7\nend\n"},
+		{"NoInput", "", "0\nend\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := closeStdinOutput(t, opts, tt.input)
+			if err != nil {
+				t.Fatalf("CloseStdin() failed: %v", err)
+			}
+			if !strings.HasSuffix(out, tt.want) {
+				t.Errorf("output = %q, want the count and the output after it (%q)", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnixSession_CloseStdinRaw(t *testing.T) {
+	raw := RawTermios()
+	s := spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo ready; sleep 10"}, Termios: &raw})
+	err := s.CloseStdin()
+	if err == nil || !strings.Contains(err.Error(), "canonical") {
+		t.Fatalf("CloseStdin() = %v, want an error about canonical mode", err)
+	}
+	if again := s.CloseStdin(); again != err {
+		t.Errorf("second CloseStdin() = %v, want the first result", again)
+	}
+	if _, err := s.PtyWriter().Write([]byte("x")); err != nil {
+		t.Errorf("Write() after a failed CloseStdin = %v, want input still open", err)
+	}
+}
+
+func TestUnixSession_WaitManyCallers(t *testing.T) {
+	s, err := Spawn(context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "read x; exit 3"}})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	defer s.Close()
+	if st := s.State(); st != StateRunning {
+		t.Errorf("State() = %v before exit, want running", st)
+	}
+	if _, exited := s.ExitStatus(); exited {
+		t.Error("ExitStatus() reported an exit while the child runs")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.WaitContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitContext() = %v, want the context's error", err)
+	}
+
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() { errs <- s.Wait() }()
+	}
+	_, _ = s.PtyWriter().Write([]byte("\n"))
+	select {
+	case <-s.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("Done() was not closed after the child exited")
+	}
+	for i := 0; i < cap(errs); i++ {
+		var ee *ExitError
+		if err := <-errs; !errors.As(err, &ee) || ee.ExitCode != 3 {
+			t.Errorf("Wait() = %v, want exit code 3 for every caller", err)
+		}
+	}
+	if err := s.WaitContext(context.Background()); exitCode(err) != 3 {
+		t.Errorf("WaitContext() = %v after exit, want the cached result", err)
+	}
+	if code, exited := s.ExitStatus(); code != 3 || !exited {
+		t.Errorf("ExitStatus() = %d, %v; want 3, true", code, exited)
+	}
+	if st := s.State(); st != StateExited {
+		t.Errorf("State() = %v after exit, want exited", st)
+	}
+	_ = s.Close()
+	if st := s.State(); st != StateClosed {
+		t.Errorf("State() = %v after Close, want closed", st)
+	}
+}
+
+func TestUnixSession_StateStarting(t *testing.T) {
+	if st := (&unixSession{done: make(chan struct{})}).State(); st != StateStarting {
+		t.Errorf("State() = %v before the waiter starts, want starting", st)
+	}
+}
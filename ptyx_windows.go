@@ -11,6 +11,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unicode/utf16"
 	"unsafe"
 
@@ -18,14 +19,29 @@ import (
 )
 
 type winSession struct {
-	con      *ConPty
-	pid      int
-	process  windows.Handle
-	thread   windows.Handle
-	job      windows.Handle
-	killed   uint32
+	con       *ConPty
+	pid       int
+	process   windows.Handle
+	thread    windows.Handle
+	job       windows.Handle
+	jobOnce   sync.Once
+	killed    uint32
 	closeOnce sync.Once
 	conOnce   sync.Once
+
+	ctx         context.Context
+	cancelSig   os.Signal
+	killDelay   time.Duration
+	termOnce    sync.Once
+	forceKilled uint32
+	killCalled  uint32
+	killedBy    int32
+
+	running atomic.Bool
+	done    chan struct{}
+	waitErr error
+	result  Result
+	closed  uint32
 }
 
 func buildCommandLine(prog string, args []string) string {
@@ -57,6 +73,15 @@ func buildEnvBlock(env []string) []uint16 {
 }
 
 func Spawn(ctx context.Context, opts SpawnOpts) (Session, error) {
+	if opts.PacketMode {
+		return nil, fmt.Errorf("ptyx: packet mode: %w", ErrUnsupported)
+	}
+	if opts.Isolation != nil || opts.Sandbox != nil {
+		return nil, fmt.Errorf("ptyx: isolation: %w", ErrUnsupported)
+	}
+	if opts.Credential != nil {
+		return nil, fmt.Errorf("ptyx: credential: %w", ErrUnsupported)
+	}
 	con, err := NewConPty(opts.Cols, opts.Rows, 0)
 	if err != nil {
 		return nil, err
@@ -169,6 +194,14 @@ func Spawn(ctx context.Context, opts SpawnOpts) (Session, error) {
 		process: pi.Process,
 		thread:  pi.Thread,
 		job:     job,
+
+		ctx:       ctx,
+		cancelSig: opts.CancelSignal,
+		killDelay: opts.KillDelay,
+		done:      make(chan struct{}),
+	}
+	if sess.killDelay == 0 {
+		sess.killDelay = defaultKillDelay
 	}
 
 	closeCon := func() {
@@ -181,11 +214,13 @@ func Spawn(ctx context.Context, opts SpawnOpts) (Session, error) {
 
 	go func() {
 		<-ctx.Done()
-		atomic.StoreUint32(&sess.killed, 1)
-		if sess.job != 0 {
-			windows.CloseHandle(sess.job)
-			sess.job = 0
+		sess.setKilledBy(KilledByContext)
+		if sess.cancelSig == os.Interrupt {
+			_ = sess.interrupt()
+			return
 		}
+		atomic.StoreUint32(&sess.killed, 1)
+		sess.closeJob()
 		_ = windows.TerminateProcess(pi.Process, 1)
 		st, _ := windows.WaitForSingleObject(pi.Process, 1500)
 		if st == uint32(windows.WAIT_TIMEOUT) {
@@ -193,20 +228,72 @@ func Spawn(ctx context.Context, opts SpawnOpts) (Session, error) {
 		}
 	}()
 
+	sess.running.Store(true)
 	go func() {
-		_, _ = windows.WaitForSingleObject(pi.Process, windows.INFINITE)
+		sess.waitErr = sess.wait()
+		sess.result.ExitCode = exitCode(sess.waitErr)
+		close(sess.done)
 		closeCon()
 	}()
 
 	return sess, nil
 }
 
+// SpawnCmd runs cmd's path, arguments, environment and directory through
+// Spawn. Windows processes cannot inherit a ConPTY as stdio, so the other
+// exec.Cmd fields are not used.
+func SpawnCmd(ctx context.Context, cmd *exec.Cmd, cols, rows int) (Session, error) {
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+	var args []string
+	if len(cmd.Args) > 1 {
+		args = cmd.Args[1:]
+	}
+	return Spawn(ctx, SpawnOpts{Prog: cmd.Path, Args: args, Env: cmd.Env, Dir: cmd.Dir, Cols: cols, Rows: rows})
+}
+
 func (s *winSession) PtyReader() io.Reader        { return s.con.outFile }
 func (s *winSession) PtyWriter() io.Writer        { return s.con.inFile }
 func (s *winSession) Resize(cols, rows int) error { return s.con.resize(cols, rows) }
 func (s *winSession) Pid() int                    { return s.pid }
 
 func (s *winSession) Wait() error {
+	<-s.done
+	return s.waitErr
+}
+
+func (s *winSession) Done() <-chan struct{} { return s.done }
+
+func (s *winSession) WaitContext(ctx context.Context) error { return waitContext(ctx, s) }
+
+func (s *winSession) ExitStatus() (int, bool) {
+	if !isDone(s.done) {
+		return 0, false
+	}
+	return s.result.ExitCode, true
+}
+
+func (s *winSession) Result() (Result, bool) {
+	if !isDone(s.done) {
+		return Result{}, false
+	}
+	return s.result, true
+}
+
+func (s *winSession) State() SessionState {
+	switch {
+	case atomic.LoadUint32(&s.closed) == 1:
+		return StateClosed
+	case isDone(s.done):
+		return StateExited
+	case s.running.Load():
+		return StateRunning
+	}
+	return StateStarting
+}
+
+func (s *winSession) wait() error {
 	st, err := windows.WaitForSingleObject(s.process, windows.INFINITE)
 	if err != nil {
 		return err
@@ -214,26 +301,79 @@ func (s *winSession) Wait() error {
 	if st != windows.WAIT_OBJECT_0 {
 		return fmt.Errorf("unexpected wait status: %d", st)
 	}
+	s.result = processResult(s.process)
 	var code uint32
 	if err := windows.GetExitCodeProcess(s.process, &code); err != nil {
 		return err
 	}
 	if atomic.LoadUint32(&s.killed) == 1 {
-		if code == 0 { return &ExitError{ExitCode: 1, waitStatus: nil} }
-		return &ExitError{ExitCode: int(code), waitStatus: nil}
+		forced := atomic.LoadUint32(&s.forceKilled) == 1
+		cause := KillCause(atomic.LoadInt32(&s.killedBy))
+		if code == 0 {
+			return &ExitError{ExitCode: 1, KilledBy: cause, ForceKilled: forced, waitStatus: nil}
+		}
+		return &ExitError{ExitCode: int(code), KilledBy: cause, ForceKilled: forced, waitStatus: nil}
 	}
 	if code == 0 {
 		return nil
 	}
-	return &ExitError{ExitCode: int(code), waitStatus: nil}
+	return &ExitError{ExitCode: int(code), KilledBy: KillCause(atomic.LoadInt32(&s.killedBy)), waitStatus: nil}
+}
+
+func (s *winSession) setKilledBy(c KillCause) {
+	if s.process == 0 || isDone(s.done) {
+		return
+	}
+	if s.ctx != nil && s.ctx.Err() != nil {
+		c = contextKillCause(s.ctx)
+	}
+	if st, _ := windows.WaitForSingleObject(s.process, 0); st == uint32(windows.WAIT_TIMEOUT) {
+		atomic.CompareAndSwapInt32(&s.killedBy, int32(KilledByNone), int32(c))
+	}
 }
 
+// Kill interrupts the process when CancelSignal is os.Interrupt and
+// escalates after KillDelay; calling it again during that grace period
+// terminates it outright.
 func (s *winSession) Kill() error {
-	atomic.StoreUint32(&s.killed, 1)
-	if s.job != 0 {
-		windows.CloseHandle(s.job)
-		s.job = 0
+	s.setKilledBy(KilledByKill)
+	again := atomic.SwapUint32(&s.killCalled, 1) == 1
+	if s.cancelSig == os.Interrupt && !again {
+		return s.interrupt()
+	}
+	if s.cancelSig == os.Interrupt {
+		atomic.StoreUint32(&s.forceKilled, 1)
+	}
+	return s.terminate()
+}
+
+// interrupt delivers Ctrl+C through the console input, which ConPTY turns
+// into a CTRL_C_EVENT for the attached processes, and escalates to
+// TerminateProcess once the kill delay has passed.
+func (s *winSession) interrupt() error {
+	var err error
+	s.termOnce.Do(func() {
+		_, err = s.con.inFile.Write([]byte{0x03})
+		if s.killDelay < 0 {
+			return
+		}
+		process := s.process
+		time.AfterFunc(s.killDelay, func() {
+			if st, _ := windows.WaitForSingleObject(process, 0); st == uint32(windows.WAIT_TIMEOUT) {
+				atomic.StoreUint32(&s.forceKilled, 1)
+				_ = s.terminate()
+			}
+		})
+	})
+	return err
+}
+
+func (s *winSession) terminate() error {
+	if isDone(s.done) {
+		return nil
 	}
+	atomic.StoreUint32(&s.killed, 1)
+	s.closeJob()
 	_ = windows.TerminateProcess(s.process, 1)
 	st, _ := windows.WaitForSingleObject(s.process, 1500)
 	if st == uint32(windows.WAIT_TIMEOUT) {
@@ -246,13 +386,22 @@ func (s *winSession) Kill() error {
 	return nil
 }
 
+// closeJob closes the job object, which kills every process still in it.
+// The context watcher, Kill and Close can all get here concurrently.
+func (s *winSession) closeJob() {
+	s.jobOnce.Do(func() {
+		if s.job != 0 {
+			_ = windows.CloseHandle(s.job)
+		}
+	})
+}
+
 func (s *winSession) Close() error {
 	var err error
+	atomic.StoreUint32(&s.closed, 1)
+	s.setKilledBy(KilledByClose)
 	s.closeOnce.Do(func() {
-		if s.job != 0 {
-			windows.CloseHandle(s.job)
-			s.job = 0
-		}
+		s.closeJob()
 		s.conOnce.Do(func() {
 			if s.con != nil {
 				if e := s.con.Close(); err == nil {
@@ -260,10 +409,11 @@ func (s *winSession) Close() error {
 				}
 			}
 		})
-		if s.process != 0 {
+		// The waiter still needs the process handle for the exit code.
+		go func() {
+			<-s.done
 			_ = windows.CloseHandle(s.process)
-			s.process = 0
-		}
+		}()
 		if s.thread != 0 {
 			_ = windows.CloseHandle(s.thread)
 			s.thread = 0
@@ -272,6 +422,28 @@ func (s *winSession) Close() error {
 	return err
 }
 
+func (s *winSession) Signal(sig Signal) error {
+	switch sig {
+	case SIGINT:
+		_, err := s.con.inFile.Write([]byte{0x03})
+		return err
+	case SIGKILL, SIGTERM, SIGHUP:
+		return s.terminate()
+	}
+	return ErrUnsupported
+}
+
+func (s *winSession) SignalForeground(sig Signal) error { return s.Signal(sig) }
+
+func (s *winSession) Interrupt(InterruptMode) error { return s.Signal(SIGINT) }
+
+func (s *winSession) GetAttr() (Termios, error) { return Termios{}, ErrUnsupported }
+func (s *winSession) SetAttr(Termios) error     { return ErrUnsupported }
+
+// ConPTY does not expose the client's console mode, so echo is assumed on.
+func (s *winSession) EchoEnabled() bool         { return true }
+func (s *winSession) OnEchoChange() <-chan bool { return nil }
+
 func (s *winSession) CloseStdin() error {
 	if s == nil || s.con == nil || s.con.inFile == nil {
 		return nil
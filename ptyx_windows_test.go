@@ -13,6 +13,7 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 	"unicode/utf16"
@@ -32,6 +33,8 @@ func TestWinHelperProcess(t *testing.T) {
 		fmt.Println(wd)
 	case "exit":
 		os.Exit(17)
+	case "sleep":
+		time.Sleep(time.Minute)
 	default:
 		fmt.Println("noop")
 	}
@@ -248,3 +251,130 @@ func spawnReadOneLineAndCloseWin(ctx context.Context, opts SpawnOpts, timeout ti
 	}
 	return "", nil
 }
+
+func TestSpawnCmd_LookPathError(t *testing.T) {
+	cmd := exec.Command("a-program-that-does-not-exist-12345")
+	if _, err := SpawnCmd(context.Background(), cmd, 80, 24); err == nil {
+		t.Fatal("SpawnCmd() should fail when the program cannot be found")
+	}
+}
+
+func TestWindowsSpawn_PacketModeUnsupported(t *testing.T) {
+	_, err := Spawn(context.Background(), SpawnOpts{Prog: "cmd.exe", PacketMode: true})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Spawn(PacketMode) = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestWinSession_OptionalInterfaces(t *testing.T) {
+	var s Session = &winSession{}
+	if _, ok := s.(PacketEventSession); ok {
+		t.Error("winSession should not implement PacketEventSession")
+	}
+	if _, ok := s.(ExitFdSession); ok {
+		t.Error("winSession should not implement ExitFdSession")
+	}
+	if _, ok := s.(SandboxSession); ok {
+		t.Error("winSession should not implement SandboxSession")
+	}
+	if _, ok := s.(CgroupSession); ok {
+		t.Error("winSession should not implement CgroupSession")
+	}
+	if _, ok := s.(ForegroundSession); ok {
+		t.Error("winSession should not implement ForegroundSession")
+	}
+}
+
+func TestWindowsSpawn_IsolationUnsupported(t *testing.T) {
+	_, err := Spawn(context.Background(), SpawnOpts{Prog: "cmd.exe", Isolation: &Isolation{User: true}})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Spawn(Isolation) = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestWindowsSpawn_SandboxUnsupported(t *testing.T) {
+	_, err := Spawn(context.Background(), SpawnOpts{Prog: "cmd.exe", Sandbox: &Sandbox{NoNewPrivs: true}})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Spawn(Sandbox) = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestWindowsSpawn_CredentialUnsupported(t *testing.T) {
+	_, err := Spawn(context.Background(), SpawnOpts{Prog: "cmd.exe", Credential: &Credential{User: "someone"}})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Spawn(Credential) = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestWinSession_WaitManyCallers(t *testing.T) {
+	s, err := Spawn(context.Background(), SpawnOpts{
+		Prog: os.Args[0],
+		Args: []string{"-test.run=^TestWinHelperProcess$"},
+		Env:  append(os.Environ(), "PTYX_HELPER=1", "MODE=exit"),
+	})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	defer s.Close()
+
+	errs := make(chan error, 3)
+	for i := 0; i < cap(errs); i++ {
+		go func() { errs <- s.Wait() }()
+	}
+	for i := 0; i < cap(errs); i++ {
+		var ee *ExitError
+		if err := <-errs; !errors.As(err, &ee) || ee.ExitCode != 17 {
+			t.Errorf("Wait() = %v, want exit code 17 for every caller", err)
+		}
+	}
+	if code, exited := s.ExitStatus(); code != 17 || !exited {
+		t.Errorf("ExitStatus() = %d, %v; want 17, true", code, exited)
+	}
+	if st := s.State(); st != StateExited {
+		t.Errorf("State() = %v after exit, want exited", st)
+	}
+	if r, ok := s.Result(); !ok || r.ExitCode != 17 || r.Duration() <= 0 || r.MaxRSS <= 0 {
+		t.Errorf("Result() = %+v, %v; want exit code 17 with times and memory", r, ok)
+	}
+	_ = s.Close()
+	if st := s.State(); st != StateClosed {
+		t.Errorf("State() = %v after Close, want closed", st)
+	}
+}
+
+func TestWinSession_StateStarting(t *testing.T) {
+	if st := (&winSession{done: make(chan struct{})}).State(); st != StateStarting {
+		t.Errorf("State() = %v before the waiter starts, want starting", st)
+	}
+}
+
+func TestWinSession_KillAndCloseRace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := Spawn(ctx, SpawnOpts{
+		Prog: os.Args[0],
+		Args: []string{"-test.run=^TestWinHelperProcess$"},
+		Env:  append(os.Environ(), "PTYX_HELPER=1", "MODE=sleep"),
+	})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	if st := s.State(); st != StateRunning {
+		t.Errorf("State() = %v after Spawn, want running", st)
+	}
+	go io.Copy(io.Discard, s.PtyReader())
+
+	var wg sync.WaitGroup
+	for _, f := range []func(){cancel, func() { _ = s.Kill() }, func() { _ = s.Close() }} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	wg.Wait()
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("process did not exit after Kill, Close and cancel")
+	}
+}
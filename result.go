@@ -0,0 +1,24 @@
+package ptyx
+
+import "time"
+
+// Result describes how a session's process ran. It is available once the
+// process has exited, whether or not it succeeded.
+type Result struct {
+	ExitCode  int
+	StartTime time.Time
+	EndTime   time.Time
+	// UserTime and SystemTime are the CPU time used by the process and by
+	// the children it waited for.
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// MaxRSS is the peak resident set size in bytes.
+	MaxRSS int64
+	// VoluntaryCtxSwitches and InvoluntaryCtxSwitches count context
+	// switches. Windows does not report them.
+	VoluntaryCtxSwitches   int64
+	InvoluntaryCtxSwitches int64
+}
+
+// Duration is the wall-clock time the process ran for.
+func (r Result) Duration() time.Duration { return r.EndTime.Sub(r.StartTime) }
@@ -0,0 +1,29 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package ptyx
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// processResult reads the rusage Wait collected. ru_maxrss is in bytes on
+// macOS and in kilobytes everywhere else.
+func processResult(ps *os.ProcessState, start, end time.Time) Result {
+	r := Result{StartTime: start, EndTime: end}
+	if ps == nil {
+		return r
+	}
+	r.UserTime, r.SystemTime = ps.UserTime(), ps.SystemTime()
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		r.MaxRSS = int64(ru.Maxrss)
+		if runtime.GOOS != "darwin" {
+			r.MaxRSS *= 1024
+		}
+		r.VoluntaryCtxSwitches = int64(ru.Nvcsw)
+		r.InvoluntaryCtxSwitches = int64(ru.Nivcsw)
+	}
+	return r
+}
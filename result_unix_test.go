@@ -0,0 +1,52 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package ptyx
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUnixSession_Result(t *testing.T) {
+	before := time.Now()
+	s, err := Spawn(context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "read x; i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done; exit 2"}})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	defer s.Close()
+	if _, ok := s.Result(); ok {
+		t.Error("Result() reported a result while the child runs")
+	}
+	_, _ = s.PtyWriter().Write([]byte("\n"))
+	_ = waitTimeout(t, s, 5*time.Second)
+	after := time.Now()
+
+	r, ok := s.Result()
+	if !ok {
+		t.Fatal("Result() not available after Wait")
+	}
+	if r.ExitCode != 2 {
+		t.Errorf("ExitCode = %d, want 2", r.ExitCode)
+	}
+	if r.StartTime.Before(before) || r.EndTime.After(after) || r.Duration() <= 0 {
+		t.Errorf("StartTime %v, EndTime %v; want an interval inside [%v, %v]", r.StartTime, r.EndTime, before, after)
+	}
+	if r.UserTime+r.SystemTime <= 0 {
+		t.Errorf("UserTime %v, SystemTime %v; want some CPU time", r.UserTime, r.SystemTime)
+	}
+	if r.MaxRSS < 64*1024 {
+		t.Errorf("MaxRSS = %d, want a size in bytes", r.MaxRSS)
+	}
+	if r.VoluntaryCtxSwitches+r.InvoluntaryCtxSwitches <= 0 {
+		t.Errorf("context switches = %d+%d, want the blocking read counted", r.VoluntaryCtxSwitches, r.InvoluntaryCtxSwitches)
+	}
+}
+
+func TestProcessResult_NoState(t *testing.T) {
+	start := time.Now()
+	end := start.Add(time.Second)
+	if r := processResult(nil, start, end); r.Duration() != time.Second || r.MaxRSS != 0 {
+		t.Errorf("processResult(nil) = %+v, want only the times", r)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build windows
+
+package ptyx
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetProcessMemoryInfo = windows.NewLazySystemDLL("kernel32.dll").NewProc("K32GetProcessMemoryInfo")
+
+type processMemoryCounters struct {
+	cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// processResult reads the times and peak working set of an exited process
+// whose handle is still open.
+func processResult(h windows.Handle) Result {
+	var r Result
+	var created, exited, kernel, user windows.Filetime
+	if windows.GetProcessTimes(h, &created, &exited, &kernel, &user) == nil {
+		r.StartTime = time.Unix(0, created.Nanoseconds())
+		r.EndTime = time.Unix(0, exited.Nanoseconds())
+		r.SystemTime = filetimeDuration(kernel)
+		r.UserTime = filetimeDuration(user)
+	}
+	pmc := processMemoryCounters{cb: uint32(unsafe.Sizeof(processMemoryCounters{}))}
+	if ok, _, _ := procGetProcessMemoryInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&pmc)), uintptr(pmc.cb)); ok != 0 {
+		r.MaxRSS = int64(pmc.PeakWorkingSetSize)
+	}
+	return r
+}
+
+// filetimeDuration converts a FILETIME holding an interval, counted in
+// 100ns ticks rather than from 1601.
+func filetimeDuration(ft windows.Filetime) time.Duration {
+	return time.Duration(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) * 100
+}
@@ -14,39 +14,71 @@ var (
 	newMuxFunc     = NewMux
 )
 
-func Run(ctx context.Context, opts SpawnOpts) error {
-	spawnCtx, spawnCancel := context.WithCancel(context.Background())
-	defer spawnCancel()
+func spawnWrapped(ctx context.Context, opts SpawnOpts) (Session, error) {
+	s, err := spawnFunc(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Wrap != nil {
+		w, err := opts.Wrap(s, opts)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		s = w
+	}
+	return s, nil
+}
+
+// stopSession ends s after ctx is done. Sessions are spawned under a context
+// of their own, and passing ctx's error on as its cancel cause records
+// KilledByContext or KilledByTimeout before any signal is sent. The result is
+// a *ContextError carrying the session's *ExitError.
+func stopSession(ctx context.Context, s Session, opts SpawnOpts, cancel context.CancelCauseFunc) error {
+	cancel(ctx.Err())
+	var err error
+	if opts.CancelSignal == nil {
+		_ = s.Close()
+		err = s.Wait()
+	} else {
+		_ = s.Kill()
+		err = s.Wait()
+		_ = s.Close()
+	}
+	var exitErr *ExitError
+	errors.As(err, &exitErr)
+	return &ContextError{Err: ctx.Err(), Exit: exitErr}
+}
 
-	go func() { <-ctx.Done(); spawnCancel() }()
+func Run(ctx context.Context, opts SpawnOpts) error {
+	spawnCtx, spawnCancel := context.WithCancelCause(context.Background())
+	defer spawnCancel(nil)
 
-	s, err := spawnFunc(spawnCtx, opts)
+	s, err := spawnWrapped(spawnCtx, opts)
 	if err != nil {
 		return err
 	}
 
-	waitCh := make(chan error, 1)
-	go func() { waitCh <- s.Wait() }()
-
 	select {
 	case <-ctx.Done():
+		return stopSession(ctx, s, opts, spawnCancel)
+	case <-s.Done():
 		_ = s.Close()
-		<-waitCh
-		return ctx.Err()
-	case err := <-waitCh:
-		_ = s.Close()
-		return err
+		return s.Wait()
 	}
 }
 
 func RunInteractive(ctx context.Context, opts SpawnOpts) error {
+	spawnCtx, spawnCancel := context.WithCancelCause(context.Background())
+	defer spawnCancel(nil)
+
 	c, err := newConsoleFunc()
 	if err != nil {
 		if !IsErrNotAConsole(err) {
 			return fmt.Errorf("failed to create console: %w", err)
 		}
 
-		s, spawnErr := spawnFunc(ctx, opts)
+		s, spawnErr := spawnWrapped(spawnCtx, opts)
 		if spawnErr != nil {
 			return fmt.Errorf("spawn failed: %w", spawnErr)
 		}
@@ -62,21 +94,19 @@ func RunInteractive(ctx context.Context, opts SpawnOpts) error {
 		}()
 		go func() { _, _ = io.Copy(os.Stdout, s.PtyReader()); close(outDone) }()
 
-		waitCh := make(chan error, 1)
-		go func() { waitCh <- s.Wait() }()
-
 		select {
 		case <-ctx.Done():
-			_ = s.Close()
-			<-waitCh
+			err := stopSession(ctx, s, opts, spawnCancel)
 			<-inDone
 			<-outDone
-			return ctx.Err()
-		case err := <-waitCh:
+			return err
+		case <-s.Done():
 			_ = s.Close()
 			<-inDone
 			<-outDone
 
+			err := s.Wait()
+
 			var exitErr *ExitError
 			if errors.As(err, &exitErr) && exitErr.ExitCode == -1 { return nil }
 			return err
@@ -93,7 +123,7 @@ func RunInteractive(ctx context.Context, opts SpawnOpts) error {
 	w, h := c.Size()
 	opts.Cols, opts.Rows = w, h
 
-	s, err := spawnFunc(ctx, opts)
+	s, err := spawnWrapped(spawnCtx, opts)
 	if err != nil {
 		return fmt.Errorf("spawn failed: %w", err)
 	}
@@ -121,15 +151,10 @@ func RunInteractive(ctx context.Context, opts SpawnOpts) error {
 		}(ch)
 	}
 
-	waitCh := make(chan error, 1)
-	go func() { waitCh <- s.Wait() }()
-
 	select {
 	case <-ctx.Done():
-		_ = s.Close()
-		<-waitCh
-		return ctx.Err()
-	case err := <-waitCh:
-		return err
+		return stopSession(ctx, s, opts, spawnCancel)
+	case <-s.Done():
+		return s.Wait()
 	}
 }
@@ -146,7 +146,7 @@ func TestRunInteractive_Console(t *testing.T) {
 
 		go io.Copy(io.Discard, s.PtyReader())
 
-		s.CloseStdin()
+		hangUp(s)
 
 		err = s.Wait()
 
@@ -307,3 +307,64 @@ func TestRunInteractive_ErrorPaths(t *testing.T) {
 		}
 	})
 }
+
+func TestRunInteractive_Wrap(t *testing.T) {
+	originalNewConsole := newConsoleFunc
+	newConsoleFunc = func() (Console, error) {
+		return newMockConsole(""), nil
+	}
+	t.Cleanup(func() { newConsoleFunc = originalNewConsole })
+
+	mockSess := newMockSession("")
+	originalSpawn := spawnFunc
+	spawnFunc = func(ctx context.Context, opts SpawnOpts) (Session, error) {
+		return mockSess, nil
+	}
+	t.Cleanup(func() { spawnFunc = originalSpawn })
+
+	var gotOpts SpawnOpts
+	wrapped := newMockSession("")
+	wrapped.waitFunc = func() error { return &ExitError{ExitCode: 7} }
+
+	err := RunInteractive(context.Background(), SpawnOpts{
+		Prog: "prog",
+		Wrap: func(s Session, opts SpawnOpts) (Session, error) {
+			if s != Session(mockSess) {
+				t.Errorf("Wrap() got session %v, want the spawned session", s)
+			}
+			gotOpts = opts
+			return wrapped, nil
+		},
+	})
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode != 7 {
+		t.Fatalf("RunInteractive() error = %v, want the wrapped session's exit error", err)
+	}
+	if gotOpts.Cols != 80 || gotOpts.Rows != 24 {
+		t.Errorf("Wrap() got size %dx%d, want the console size 80x24", gotOpts.Cols, gotOpts.Rows)
+	}
+}
+
+func TestRun_WrapError(t *testing.T) {
+	mockSess := newMockSession("")
+	closed := false
+	mockSess.closeFunc = func() error { closed = true; return nil }
+	originalSpawn := spawnFunc
+	spawnFunc = func(ctx context.Context, opts SpawnOpts) (Session, error) {
+		return mockSess, nil
+	}
+	t.Cleanup(func() { spawnFunc = originalSpawn })
+
+	wrapErr := errors.New("no recorder")
+	err := Run(context.Background(), SpawnOpts{
+		Prog: "prog",
+		Wrap: func(Session, SpawnOpts) (Session, error) { return nil, wrapErr },
+	})
+	if !errors.Is(err, wrapErr) {
+		t.Fatalf("Run() error = %v, want the Wrap error", err)
+	}
+	if !closed {
+		t.Error("the spawned session should be closed when Wrap fails")
+	}
+}
@@ -7,6 +7,10 @@ import (
 	"syscall"
 )
 
+// hangUp ends an interactive child the way closing its terminal window
+// would; CloseStdin only sends end-of-input, which a raw console ignores.
+func hangUp(s Session) { _ = s.Signal(SIGHUP) }
+
 func isExpectedWaitErrorAfterPTYClose(err error) bool {
 	var exitErr *ExitError
 	if errors.As(err, &exitErr) {
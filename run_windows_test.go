@@ -2,6 +2,8 @@
 
 package ptyx
 
+func hangUp(s Session) { _ = s.CloseStdin() }
+
 func isExpectedWaitErrorAfterPTYClose(err error) bool {
 	return err == nil
 }
@@ -0,0 +1,84 @@
+package ptyx
+
+// Sandbox is a confinement profile applied to the process after it is
+// forked and just before it execs the program (Linux only). Requesting
+// Seccomp or Landlock also turns on NoNewPrivs, which both need.
+type Sandbox struct {
+	Rlimits    []Rlimit
+	NoNewPrivs bool
+	Seccomp    *Seccomp
+	// Landlock, when non-empty, denies filesystem access outside these
+	// rules. The program itself and its libraries must be covered.
+	Landlock []LandlockRule
+}
+
+type RlimitResource int
+
+const (
+	RlimitCPU    RlimitResource = iota + 1 // CPU seconds
+	RlimitAS                               // address space in bytes
+	RlimitNOFILE                           // open files
+	RlimitNPROC                            // processes of the real user
+	RlimitCORE                             // core file size in bytes
+)
+
+// RlimitInfinity lifts a limit.
+const RlimitInfinity = ^uint64(0)
+
+func (r RlimitResource) String() string {
+	switch r {
+	case RlimitCPU:
+		return "cpu"
+	case RlimitAS:
+		return "as"
+	case RlimitNOFILE:
+		return "nofile"
+	case RlimitNPROC:
+		return "nproc"
+	case RlimitCORE:
+		return "core"
+	}
+	return "unknown"
+}
+
+type Rlimit struct {
+	Resource RlimitResource
+	Cur      uint64
+	Max      uint64
+}
+
+// Seccomp filters syscalls by name. Syscalls on Deny fail with EPERM, or
+// kill the process if Kill is set. A non-empty Allow denies everything not
+// on it too, except the calls Go makes on the way to exec the program,
+// listed in seccompStartup in sandbox_linux.go.
+type Seccomp struct {
+	Allow []string
+	Deny  []string
+	Kill  bool
+}
+
+type LandlockAccess uint8
+
+const (
+	LandlockRead LandlockAccess = 1 << iota
+	LandlockWrite
+	LandlockExec
+)
+
+// LandlockRule grants Access to Path and everything beneath it.
+type LandlockRule struct {
+	Path   string
+	Access LandlockAccess
+}
+
+// SandboxReport describes what a Sandbox actually enforced.
+type SandboxReport struct {
+	Rlimits     []Rlimit
+	NoNewPrivs  bool
+	Seccomp     bool
+	Landlock    bool
+	LandlockABI int
+	// Skipped explains each requested protection the kernel or
+	// architecture could not enforce.
+	Skipped []string
+}
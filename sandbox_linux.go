@@ -0,0 +1,245 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var rlimitResources = map[RlimitResource]int{
+	RlimitCPU:    unix.RLIMIT_CPU,
+	RlimitAS:     unix.RLIMIT_AS,
+	RlimitNOFILE: unix.RLIMIT_NOFILE,
+	RlimitNPROC:  unix.RLIMIT_NPROC,
+	RlimitCORE:   unix.RLIMIT_CORE,
+}
+
+// seccompStartup are always allowed so the filtered thread can exec: the
+// filter is installed before syscall.Exec, which still restores
+// RLIMIT_NOFILE with prlimit64 and may allocate, take runtime locks or be
+// preempted on the way to execve.
+var seccompStartup = []string{
+	"execve", "prlimit64",
+	"mmap", "munmap", "madvise",
+	"futex", "sched_yield", "nanosleep",
+	"rt_sigprocmask", "rt_sigreturn", "exit_group",
+}
+
+type sandbox struct {
+	report  *SandboxReport
+	rlimits []Rlimit
+	filter  []unix.SockFilter
+}
+
+// prepareSandbox applies the thread-bound parts of the profile to the
+// calling thread and compiles the rest. Rlimits are process-wide and
+// seccomp would also bind the init, so both wait for lockdown.
+func prepareSandbox(sb Sandbox) (*sandbox, error) {
+	s := &sandbox{report: &SandboxReport{}, rlimits: sb.Rlimits}
+	for _, rl := range sb.Rlimits {
+		if _, ok := rlimitResources[rl.Resource]; !ok {
+			return nil, &IsolationError{Op: "setrlimit", Err: fmt.Errorf("unknown resource %d", rl.Resource)}
+		}
+		if rl.Cur > rl.Max {
+			return nil, &IsolationError{Op: "setrlimit", Err: fmt.Errorf("%v: soft limit above hard limit", rl.Resource)}
+		}
+	}
+
+	if sb.NoNewPrivs || sb.Seccomp != nil || len(sb.Landlock) > 0 {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return nil, &IsolationError{Op: "no_new_privs", Err: err}
+		}
+		s.report.NoNewPrivs = true
+	}
+
+	if len(sb.Landlock) > 0 {
+		abi, err := applyLandlock(sb.Landlock)
+		switch {
+		case errors.Is(err, ErrUnsupported):
+			s.report.Skipped = append(s.report.Skipped, "landlock: not supported by this kernel")
+		case err != nil:
+			return nil, &IsolationError{Op: "landlock", Err: err}
+		default:
+			s.report.Landlock, s.report.LandlockABI = true, abi
+		}
+	}
+
+	if sb.Seccomp != nil {
+		filter, err := compileSeccomp(*sb.Seccomp)
+		switch {
+		case errors.Is(err, ErrUnsupported):
+			s.report.Skipped = append(s.report.Skipped, "seccomp: unsupported architecture")
+		case err != nil:
+			return nil, &IsolationError{Op: "seccomp", Err: err}
+		default:
+			s.filter = filter
+			s.report.Seccomp = true
+		}
+	}
+	s.report.Rlimits = sb.Rlimits
+	return s, nil
+}
+
+func (s *sandbox) setRlimits() error {
+	for _, rl := range s.rlimits {
+		if err := unix.Setrlimit(rlimitResources[rl.Resource], &unix.Rlimit{Cur: rl.Cur, Max: rl.Max}); err != nil {
+			return &IsolationError{Op: "setrlimit", Err: fmt.Errorf("%v: %w", rl.Resource, err)}
+		}
+	}
+	return nil
+}
+
+func (s *sandbox) installSeccomp() error {
+	if len(s.filter) == 0 {
+		return nil
+	}
+	prog := unix.SockFprog{Len: uint16(len(s.filter)), Filter: &s.filter[0]}
+	return unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0)
+}
+
+func syscallNumber(name string) (uintptr, bool) {
+	if nr, ok := seccompSyscalls[name]; ok {
+		return nr, true
+	}
+	nr, ok := seccompArchSyscalls[name]
+	return nr, ok
+}
+
+func bpfStmt(code uint16, k uint32) unix.SockFilter { return unix.SockFilter{Code: code, K: k} }
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
+
+// compileSeccomp builds a filter that checks the architecture, then tests
+// the syscall number against each rule in turn. Every rule is a compare and
+// a return so no jump offset can overflow.
+func compileSeccomp(sc Seccomp) ([]unix.SockFilter, error) {
+	if seccompArch == 0 {
+		return nil, ErrUnsupported
+	}
+	const (
+		ld  = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+		jeq = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jge = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+		ret = unix.BPF_RET | unix.BPF_K
+	)
+	deny := uint32(unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM))
+	if sc.Kill {
+		deny = unix.SECCOMP_RET_KILL_PROCESS
+	}
+	def := uint32(unix.SECCOMP_RET_ALLOW)
+	if len(sc.Allow) > 0 {
+		def = deny
+	}
+
+	prog := []unix.SockFilter{
+		bpfStmt(ld, 4), // seccomp_data.arch
+		bpfJump(jeq, seccompArch, 1, 0),
+		bpfStmt(ret, unix.SECCOMP_RET_KILL_PROCESS),
+		bpfStmt(ld, 0), // seccomp_data.nr
+	}
+	if seccompArch == unix.AUDIT_ARCH_X86_64 {
+		// x32 syscalls share the x86-64 arch value and set this bit.
+		prog = append(prog, bpfJump(jge, 0x40000000, 0, 1), bpfStmt(ret, deny))
+	}
+	add := func(names []string, action uint32) error {
+		for _, name := range names {
+			nr, ok := syscallNumber(name)
+			if !ok {
+				return fmt.Errorf("unknown syscall %q", name)
+			}
+			prog = append(prog, bpfJump(jeq, uint32(nr), 0, 1), bpfStmt(ret, action))
+		}
+		return nil
+	}
+	if err := add(sc.Deny, deny); err != nil {
+		return nil, err
+	}
+	if len(sc.Allow) > 0 {
+		if err := add(append(seccompStartup, sc.Allow...), unix.SECCOMP_RET_ALLOW); err != nil {
+			return nil, err
+		}
+	}
+	prog = append(prog, bpfStmt(ret, def))
+	if len(prog) > 4096 {
+		return nil, fmt.Errorf("%d rules exceed the BPF program limit", len(sc.Allow)+len(sc.Deny))
+	}
+	return prog, nil
+}
+
+const (
+	landlockFileRights = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	landlockRead  = unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	landlockWrite = unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE | unix.LANDLOCK_ACCESS_FS_MAKE_CHAR | unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG | unix.LANDLOCK_ACCESS_FS_MAKE_SOCK | unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK | unix.LANDLOCK_ACCESS_FS_MAKE_SYM | unix.LANDLOCK_ACCESS_FS_REFER |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE
+)
+
+func landlockRights(a LandlockAccess) uint64 {
+	var rights uint64
+	if a&LandlockRead != 0 {
+		rights |= landlockRead
+	}
+	if a&LandlockWrite != 0 {
+		rights |= landlockWrite
+	}
+	if a&LandlockExec != 0 {
+		rights |= unix.LANDLOCK_ACCESS_FS_EXECUTE
+	}
+	return rights
+}
+
+// applyLandlock restricts the calling thread to rules, handling every
+// filesystem right the running kernel's Landlock ABI knows about.
+func applyLandlock(rules []LandlockRule) (int, error) {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, ErrUnsupported
+	}
+	handled := uint64(landlockRead | landlockWrite | unix.LANDLOCK_ACCESS_FS_EXECUTE)
+	if abi < 2 {
+		handled &^= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi < 3 {
+		handled &^= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	rfd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return 0, fmt.Errorf("create ruleset: %w", errno)
+	}
+	defer unix.Close(int(rfd))
+
+	for _, r := range rules {
+		fd, err := unix.Open(r.Path, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", r.Path, err)
+		}
+		rights := landlockRights(r.Access) & handled
+		var st unix.Stat_t
+		if err := unix.Fstat(fd, &st); err == nil && st.Mode&unix.S_IFMT != unix.S_IFDIR {
+			rights &= landlockFileRights
+		}
+		if rights != 0 {
+			pb := unix.LandlockPathBeneathAttr{Allowed_access: rights, Parent_fd: int32(fd)}
+			_, _, errno = unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, rfd, unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&pb)), 0, 0, 0)
+		}
+		_ = unix.Close(fd)
+		if errno != 0 {
+			return 0, fmt.Errorf("%s: %w", r.Path, errno)
+		}
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, rfd, 0, 0); errno != 0 {
+		return 0, fmt.Errorf("restrict self: %w", errno)
+	}
+	return int(abi), nil
+}
@@ -0,0 +1,372 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func runSandboxed(t *testing.T, opts SpawnOpts, script string) (string, *SandboxReport) {
+	t.Helper()
+	opts.Prog, opts.Args = "sh", []string{"-c", script}
+	s, err := Spawn(context.Background(), opts)
+	if errors.Is(err, ErrUserNamespacesDisabled) {
+		t.Skipf("user namespaces unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Spawn() failed: %v", err)
+	}
+	defer s.Close()
+	out := make(chan string, 1)
+	go func() {
+		var b strings.Builder
+		buf := make([]byte, 4096)
+		for {
+			n, err := s.PtyReader().Read(buf)
+			b.Write(buf[:n])
+			if err != nil {
+				break
+			}
+		}
+		out <- strings.ReplaceAll(b.String(), "\r\n", "\n")
+	}()
+	_ = waitTimeout(t, s, 5*time.Second)
+	_ = s.Close()
+	return <-out, s.(SandboxSession).SandboxReport()
+}
+
+func TestSandbox_Rlimits(t *testing.T) {
+	out, rep := runSandboxed(t, SpawnOpts{Sandbox: &Sandbox{Rlimits: []Rlimit{
+		{Resource: RlimitNOFILE, Cur: 64, Max: 64},
+		{Resource: RlimitCORE, Cur: 0, Max: 0},
+	}}}, "ulimit -n; ulimit -c")
+	if out != "64\n0\n" {
+		t.Errorf("output = %q, want the limits applied", out)
+	}
+	if rep == nil || len(rep.Rlimits) != 2 || rep.NoNewPrivs || rep.Seccomp || rep.Landlock {
+		t.Errorf("report = %+v", rep)
+	}
+}
+
+func TestSandbox_NoNewPrivs(t *testing.T) {
+	out, rep := runSandboxed(t, SpawnOpts{Sandbox: &Sandbox{NoNewPrivs: true}}, "grep NoNewPrivs /proc/self/status")
+	if !strings.Contains(out, "NoNewPrivs:\t1") {
+		t.Errorf("output = %q, want no_new_privs set", out)
+	}
+	if rep == nil || !rep.NoNewPrivs {
+		t.Errorf("report = %+v", rep)
+	}
+}
+
+func TestSandbox_SeccompDeny(t *testing.T) {
+	if seccompArch == 0 {
+		t.Skip("seccomp rules not supported on this architecture")
+	}
+	var deny []string
+	for _, name := range []string{"mkdir", "mkdirat"} {
+		if _, ok := syscallNumber(name); ok {
+			deny = append(deny, name)
+		}
+	}
+	dir := t.TempDir()
+	out, rep := runSandboxed(t, SpawnOpts{Sandbox: &Sandbox{Seccomp: &Seccomp{Deny: deny}}},
+		"mkdir "+dir+"/x 2>/dev/null && echo made || echo denied")
+	if out != "denied\n" {
+		t.Errorf("output = %q, want mkdir denied", out)
+	}
+	if rep == nil || !rep.Seccomp || !rep.NoNewPrivs {
+		t.Errorf("report = %+v", rep)
+	}
+}
+
+func TestSandbox_SeccompKill(t *testing.T) {
+	if seccompArch == 0 {
+		t.Skip("seccomp rules not supported on this architecture")
+	}
+	out, _ := runSandboxed(t, SpawnOpts{Sandbox: &Sandbox{Seccomp: &Seccomp{Deny: []string{"uname"}, Kill: true}}},
+		"uname; echo rc=$?")
+	if !strings.Contains(out, "rc=159") {
+		t.Errorf("output = %q, want uname killed by SIGSYS", out)
+	}
+}
+
+func TestSandbox_SeccompAllowKill(t *testing.T) {
+	if seccompArch == 0 {
+		t.Skip("seccomp rules not supported on this architecture")
+	}
+	var allow []string
+	for _, name := range strings.Fields(`read write openat open close fstat newfstatat statx mprotect brk
+		pread64 access arch_prctl set_tid_address set_robust_list rseq getrandom ioctl lseek exit`) {
+		if _, ok := syscallNumber(name); ok {
+			allow = append(allow, name)
+		}
+	}
+	s, err := Spawn(context.Background(), SpawnOpts{Prog: "/bin/echo", Args: []string{"hello"},
+		Sandbox: &Sandbox{Seccomp: &Seccomp{Allow: allow, Kill: true}}})
+	if err != nil {
+		t.Fatalf("Spawn() failed: %v", err)
+	}
+	defer s.Close()
+	out := make(chan string, 1)
+	go func() {
+		b, _ := io.ReadAll(s.PtyReader())
+		out <- string(b)
+	}()
+	if err := waitTimeout(t, s, 5*time.Second); err != nil {
+		t.Fatalf("Wait() = %v, want echo to exec and exit under the filter", err)
+	}
+	_ = s.Close()
+	if got := <-out; got != "hello\r\n" {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestSandbox_Landlock(t *testing.T) {
+	allowed, denied := t.TempDir(), t.TempDir()
+	out, rep := runSandboxed(t, SpawnOpts{Sandbox: &Sandbox{Landlock: []LandlockRule{
+		{Path: "/", Access: LandlockRead | LandlockExec},
+		{Path: "/dev", Access: LandlockRead | LandlockWrite},
+		{Path: allowed, Access: LandlockRead | LandlockWrite},
+	}}}, "touch "+allowed+"/x && echo allowed; touch "+denied+"/x 2>/dev/null || echo denied")
+	if rep != nil && slices.ContainsFunc(rep.Skipped, func(s string) bool { return strings.HasPrefix(s, "landlock") }) {
+		t.Skip("Landlock not supported by this kernel")
+	}
+	if out != "allowed\ndenied\n" {
+		t.Errorf("output = %q", out)
+	}
+	if rep == nil || !rep.Landlock || rep.LandlockABI < 1 {
+		t.Errorf("report = %+v", rep)
+	}
+	if _, err := os.Stat(filepath.Join(denied, "x")); err == nil {
+		t.Error("file created outside the Landlock rules")
+	}
+}
+
+func TestSandbox_PIDNamespace(t *testing.T) {
+	out, rep := runSandboxed(t, SpawnOpts{
+		Isolation: &Isolation{User: true, PID: true},
+		Sandbox:   &Sandbox{Rlimits: []Rlimit{{Resource: RlimitNOFILE, Cur: 32, Max: 32}}},
+	}, "echo $PPID; ulimit -n")
+	if out != "1\n32\n" {
+		t.Errorf("output = %q, want the program under pid 1 with its limit", out)
+	}
+	if rep == nil || len(rep.Rlimits) != 1 {
+		t.Errorf("report = %+v", rep)
+	}
+}
+
+func TestSandbox_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		sb   Sandbox
+		op   string
+	}{
+		{"UnknownSyscall", Sandbox{Seccomp: &Seccomp{Deny: []string{"not_a_syscall"}}}, "seccomp"},
+		{"SoftAboveHard", Sandbox{Rlimits: []Rlimit{{Resource: RlimitCPU, Cur: 10, Max: 5}}}, "setrlimit"},
+		{"UnknownResource", Sandbox{Rlimits: []Rlimit{{Resource: 99}}}, "setrlimit"},
+		{"MissingLandlockPath", Sandbox{Landlock: []LandlockRule{{Path: "/nonexistent-ptyx-path", Access: LandlockRead}}}, "landlock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Spawn(context.Background(), SpawnOpts{Prog: "true", Sandbox: &tt.sb})
+			var isoErr *IsolationError
+			if tt.op == "landlock" && err == nil {
+				t.Skip("Landlock not supported by this kernel")
+			}
+			if !errors.As(err, &isoErr) || isoErr.Op != tt.op {
+				t.Errorf("Spawn() = %v, want a %q IsolationError", err, tt.op)
+			}
+		})
+	}
+}
+
+// TestSandbox_ExecError checks that an exec failing after the init reported
+// ready comes back from Spawn rather than being printed on the tty.
+func TestSandbox_ExecError(t *testing.T) {
+	prog := filepath.Join(t.TempDir(), "not-an-executable")
+	if err := os.WriteFile(prog, []byte("\x00\x01garbage"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, iso := range []*Isolation{nil, {PID: true}} {
+		_, err := Spawn(context.Background(), SpawnOpts{Prog: prog, Isolation: iso, Sandbox: &Sandbox{NoNewPrivs: true}})
+		var isoErr *IsolationError
+		if !errors.As(err, &isoErr) || isoErr.Op != "exec" || !strings.Contains(err.Error(), "exec format error") {
+			t.Errorf("Spawn(Isolation %+v) = %v, want an exec IsolationError", iso, err)
+		}
+	}
+}
+
+func TestSandboxReport_NilWithoutProfile(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo ready"}})
+	if rep := s.(SandboxSession).SandboxReport(); rep != nil {
+		t.Errorf("SandboxReport() = %+v, want nil", rep)
+	}
+	_ = waitTimeout(t, s, 3*time.Second)
+}
+
+// runBPF interprets the subset of classic BPF compileSeccomp emits.
+func runBPF(t *testing.T, prog []unix.SockFilter, arch, nr uint32) uint32 {
+	t.Helper()
+	var acc uint32
+	for pc := 0; pc < len(prog); pc++ {
+		in := prog[pc]
+		switch in.Code {
+		case unix.BPF_LD | unix.BPF_W | unix.BPF_ABS:
+			acc = nr
+			if in.K == 4 {
+				acc = arch
+			}
+		case unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K:
+			if acc == in.K {
+				pc += int(in.Jt)
+			} else {
+				pc += int(in.Jf)
+			}
+		case unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K:
+			if acc >= in.K {
+				pc += int(in.Jt)
+			} else {
+				pc += int(in.Jf)
+			}
+		case unix.BPF_RET | unix.BPF_K:
+			return in.K
+		default:
+			t.Fatalf("unexpected instruction %#x", in.Code)
+		}
+	}
+	t.Fatal("program fell off the end")
+	return 0
+}
+
+func TestCompileSeccomp(t *testing.T) {
+	if seccompArch == 0 {
+		t.Skip("seccomp rules not supported on this architecture")
+	}
+	nr := func(name string) uint32 {
+		n, ok := syscallNumber(name)
+		if !ok {
+			t.Fatalf("no syscall %q", name)
+		}
+		return uint32(n)
+	}
+	eperm := uint32(unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM))
+
+	prog, err := compileSeccomp(Seccomp{Allow: []string{"read", "write"}, Deny: []string{"write"}})
+	if err != nil {
+		t.Fatalf("compileSeccomp() failed: %v", err)
+	}
+	for _, tt := range []struct {
+		name string
+		arch uint32
+		nr   uint32
+		want uint32
+	}{
+		{"Allowed", seccompArch, nr("read"), unix.SECCOMP_RET_ALLOW},
+		{"DenyWins", seccompArch, nr("write"), eperm},
+		{"NotListed", seccompArch, nr("openat"), eperm},
+		{"Startup", seccompArch, nr("execve"), unix.SECCOMP_RET_ALLOW},
+		{"WrongArch", 0x40000003, nr("read"), unix.SECCOMP_RET_KILL_PROCESS},
+	} {
+		if got := runBPF(t, prog, tt.arch, tt.nr); got != tt.want {
+			t.Errorf("%s: action = %#x, want %#x", tt.name, got, tt.want)
+		}
+	}
+	if seccompArch == unix.AUDIT_ARCH_X86_64 {
+		if got := runBPF(t, prog, seccompArch, 0x40000000|nr("read")); got != eperm {
+			t.Errorf("x32 syscall action = %#x, want %#x", got, eperm)
+		}
+	}
+
+	prog, _ = compileSeccomp(Seccomp{Deny: []string{"read"}, Kill: true})
+	if got := runBPF(t, prog, seccompArch, nr("read")); got != unix.SECCOMP_RET_KILL_PROCESS {
+		t.Errorf("denied with Kill = %#x", got)
+	}
+	if got := runBPF(t, prog, seccompArch, nr("write")); got != unix.SECCOMP_RET_ALLOW {
+		t.Errorf("unlisted without Allow = %#x", got)
+	}
+
+	if _, err := compileSeccomp(Seccomp{Allow: []string{"bogus"}}); err == nil || !strings.Contains(err.Error(), "bogus") {
+		t.Errorf("compileSeccomp() = %v, want an unknown syscall error", err)
+	}
+}
+
+func TestRlimitResource_String(t *testing.T) {
+	for r, want := range map[RlimitResource]string{RlimitCPU: "cpu", RlimitAS: "as", RlimitNOFILE: "nofile", RlimitNPROC: "nproc", RlimitCORE: "core", 0: "unknown"} {
+		if got := r.String(); got != want {
+			t.Errorf("RlimitResource(%d).String() = %q, want %q", int(r), got, want)
+		}
+	}
+}
+
+// TestSandbox_InProcess applies a profile on a locked thread that is thrown
+// away afterwards, since no_new_privs, Landlock and seccomp are per thread.
+func TestSandbox_InProcess(t *testing.T) {
+	allowed, denied := t.TempDir(), t.TempDir()
+	var cur unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &cur); err != nil {
+		t.Fatal(err)
+	}
+	sb := Sandbox{
+		Rlimits:  []Rlimit{{Resource: RlimitNOFILE, Cur: cur.Cur, Max: cur.Max}},
+		Landlock: []LandlockRule{{Path: allowed, Access: LandlockRead | LandlockWrite | LandlockExec}},
+	}
+	if seccompArch != 0 {
+		sb.Seccomp = &Seccomp{Deny: []string{"fchmodat"}}
+	}
+
+	type result struct {
+		rep             *SandboxReport
+		err             error
+		allowed, denied error
+		chmod           error
+	}
+	done := make(chan result, 1)
+	go func() {
+		runtime.LockOSThread()
+		var r result
+		s, err := prepareSandbox(sb)
+		if err != nil {
+			r.err = err
+			done <- r
+			return
+		}
+		if err := s.setRlimits(); err != nil {
+			r.err = err
+		} else if err := s.installSeccomp(); err != nil {
+			r.err = err
+		}
+		r.rep = s.report
+		r.allowed = unix.Mkdir(filepath.Join(allowed, "d"), 0o755)
+		r.denied = unix.Mkdir(filepath.Join(denied, "d"), 0o755)
+		r.chmod = unix.Chmod(allowed, 0o700)
+		done <- r
+	}()
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("sandbox setup failed: %v", r.err)
+	}
+	if !r.rep.NoNewPrivs || len(r.rep.Rlimits) != 1 {
+		t.Errorf("report = %+v", r.rep)
+	}
+	if r.rep.Landlock {
+		if r.allowed != nil || !errors.Is(r.denied, unix.EACCES) {
+			t.Errorf("Mkdir() = %v inside and %v outside the rules, want nil and EACCES", r.allowed, r.denied)
+		}
+	} else if len(r.rep.Skipped) == 0 {
+		t.Errorf("Landlock neither applied nor skipped: %+v", r.rep)
+	}
+	if r.rep.Seccomp && !errors.Is(r.chmod, unix.EPERM) {
+		t.Errorf("Chmod() = %v under a seccomp deny rule, want EPERM", r.chmod)
+	}
+}
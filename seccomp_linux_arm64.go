@@ -0,0 +1,10 @@
+//go:build linux
+
+package ptyx
+
+import "golang.org/x/sys/unix"
+
+const seccompArch = unix.AUDIT_ARCH_AARCH64
+
+// arm64 only has the generic syscall table.
+var seccompArchSyscalls = map[string]uintptr{}
@@ -0,0 +1,11 @@
+//go:build linux && !amd64 && !arm64
+
+package ptyx
+
+// Seccomp rules are only compiled for amd64 and arm64.
+const seccompArch = 0
+
+var (
+	seccompSyscalls     map[string]uintptr
+	seccompArchSyscalls map[string]uintptr
+)
@@ -0,0 +1,126 @@
+package ptyx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Signal is a portable signal. Values follow Linux numbering and are mapped
+// by name to the native signal of the running platform when delivered.
+type Signal int
+
+const (
+	SIGHUP    Signal = 1
+	SIGINT    Signal = 2
+	SIGQUIT   Signal = 3
+	SIGILL    Signal = 4
+	SIGTRAP   Signal = 5
+	SIGABRT   Signal = 6
+	SIGBUS    Signal = 7
+	SIGFPE    Signal = 8
+	SIGKILL   Signal = 9
+	SIGUSR1   Signal = 10
+	SIGSEGV   Signal = 11
+	SIGUSR2   Signal = 12
+	SIGPIPE   Signal = 13
+	SIGALRM   Signal = 14
+	SIGTERM   Signal = 15
+	SIGCHLD   Signal = 17
+	SIGCONT   Signal = 18
+	SIGSTOP   Signal = 19
+	SIGTSTP   Signal = 20
+	SIGTTIN   Signal = 21
+	SIGTTOU   Signal = 22
+	SIGURG    Signal = 23
+	SIGXCPU   Signal = 24
+	SIGXFSZ   Signal = 25
+	SIGVTALRM Signal = 26
+	SIGPROF   Signal = 27
+	SIGWINCH  Signal = 28
+	SIGIO     Signal = 29
+	SIGSYS    Signal = 31
+)
+
+// nativeSignalBase is added to the number of a platform signal that has no
+// portable name, such as a realtime signal outside Linux, so it cannot be
+// mistaken for the portable signal that shares its number.
+const nativeSignalBase Signal = 1 << 16
+
+var signalNames = map[Signal]string{
+	SIGHUP:    "SIGHUP",
+	SIGINT:    "SIGINT",
+	SIGQUIT:   "SIGQUIT",
+	SIGILL:    "SIGILL",
+	SIGTRAP:   "SIGTRAP",
+	SIGABRT:   "SIGABRT",
+	SIGBUS:    "SIGBUS",
+	SIGFPE:    "SIGFPE",
+	SIGKILL:   "SIGKILL",
+	SIGUSR1:   "SIGUSR1",
+	SIGSEGV:   "SIGSEGV",
+	SIGUSR2:   "SIGUSR2",
+	SIGPIPE:   "SIGPIPE",
+	SIGALRM:   "SIGALRM",
+	SIGTERM:   "SIGTERM",
+	SIGCHLD:   "SIGCHLD",
+	SIGCONT:   "SIGCONT",
+	SIGSTOP:   "SIGSTOP",
+	SIGTSTP:   "SIGTSTP",
+	SIGTTIN:   "SIGTTIN",
+	SIGTTOU:   "SIGTTOU",
+	SIGURG:    "SIGURG",
+	SIGXCPU:   "SIGXCPU",
+	SIGXFSZ:   "SIGXFSZ",
+	SIGVTALRM: "SIGVTALRM",
+	SIGPROF:   "SIGPROF",
+	SIGWINCH:  "SIGWINCH",
+	SIGIO:     "SIGIO",
+	SIGSYS:    "SIGSYS",
+}
+
+func (s Signal) String() string {
+	if name, ok := signalNames[s]; ok {
+		return name
+	}
+	if s >= nativeSignalBase {
+		return "native signal " + strconv.Itoa(int(s-nativeSignalBase))
+	}
+	return "signal " + strconv.Itoa(int(s))
+}
+
+func (s Signal) Signal() {}
+
+func (s Signal) Number() int { return int(s) }
+
+// ParseSignal accepts names with or without the SIG prefix, in any case,
+// and portable signal numbers.
+func ParseSignal(name string) (Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if _, ok := signalNames[Signal(n)]; ok {
+			return Signal(n), nil
+		}
+		return 0, fmt.Errorf("ptyx: unknown signal %q", name)
+	}
+	want := strings.ToUpper(name)
+	if !strings.HasPrefix(want, "SIG") {
+		want = "SIG" + want
+	}
+	for sig, n := range signalNames {
+		if n == want {
+			return sig, nil
+		}
+	}
+	return 0, fmt.Errorf("ptyx: unknown signal %q", name)
+}
+
+type InterruptMode int
+
+const (
+	// InterruptBySignal sends SIGINT to the terminal's foreground process
+	// group, the way the line discipline does when ^C is typed.
+	InterruptBySignal InterruptMode = iota
+	// InterruptByChar writes the terminal's VINTR character to the PTY, so
+	// programs in raw mode see the keystroke instead of a signal.
+	InterruptByChar
+)
@@ -0,0 +1,46 @@
+package ptyx
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSignal(t *testing.T) {
+	var _ os.Signal = SIGINT
+	if SIGINT.String() != "SIGINT" || SIGINT.Number() != 2 {
+		t.Errorf("SIGINT = %q/%d", SIGINT.String(), SIGINT.Number())
+	}
+	if got := Signal(64).String(); got != "signal 64" {
+		t.Errorf("Signal(64).String() = %q", got)
+	}
+	if got := (nativeSignalBase + 33).String(); got != "native signal 33" {
+		t.Errorf("native signal String() = %q", got)
+	}
+	if SIGSEGV.String() != "SIGSEGV" || SIGSYS.Number() != 31 {
+		t.Errorf("SIGSEGV = %q, SIGSYS = %d", SIGSEGV.String(), SIGSYS.Number())
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Signal
+		wantErr bool
+	}{
+		{"SIGTERM", SIGTERM, false},
+		{"term", SIGTERM, false},
+		{"Winch", SIGWINCH, false},
+		{"9", SIGKILL, false},
+		{"segv", SIGSEGV, false},
+		{"11", SIGSEGV, false},
+		{"64", 0, true},
+		{"SIGBOGUS", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSignal(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSignal(%q) = %v, %v; want %v, err %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ptyx
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// SessionState is the lifecycle stage of a Session.
+type SessionState int32
+
+const (
+	// StateStarting covers the spawn handshake, before the process is
+	// handed to the caller.
+	StateStarting SessionState = iota
+	StateRunning
+	// StateExited means the process has exited and Wait no longer blocks.
+	StateExited
+	// StateClosed means Close has been called, whether or not the process
+	// had exited.
+	StateClosed
+)
+
+var stateNames = [...]string{"starting", "running", "exited", "closed"}
+
+func (s SessionState) String() string {
+	if s >= 0 && int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return "state " + strconv.Itoa(int(s))
+}
+
+// exitCode maps a Wait result to the code reported by ExitStatus: 0 on
+// success, the exit code of an ExitError, and -1 for any other failure.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ee *ExitError
+	if errors.As(err, &ee) {
+		return ee.ExitCode
+	}
+	return -1
+}
+
+func isDone(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitContext waits like Wait but gives up with ctx.Err() once ctx is done.
+// The process keeps running.
+func waitContext(ctx context.Context, s Session) error {
+	select {
+	case <-s.Done():
+		return s.Wait()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,38 @@
+package ptyx
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSessionState_String(t *testing.T) {
+	for st, want := range map[SessionState]string{
+		StateStarting: "starting",
+		StateRunning:  "running",
+		StateExited:   "exited",
+		StateClosed:   "closed",
+		7:             "state 7",
+	} {
+		if got := st.String(); got != want {
+			t.Errorf("SessionState(%d).String() = %q, want %q", int(st), got, want)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{&ExitError{ExitCode: 4}, 4},
+		{fmt.Errorf("wrapped: %w", &ExitError{ExitCode: 2}), 2},
+		{errors.New("wait failed"), -1},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
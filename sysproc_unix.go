@@ -7,3 +7,13 @@ import "syscall"
 func newSysProcAttr() *syscall.SysProcAttr {
 	return &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
 }
+
+func mergeSysProcAttr(a *syscall.SysProcAttr) *syscall.SysProcAttr {
+	if a == nil {
+		return newSysProcAttr()
+	}
+	merged := *a
+	merged.Setsid, merged.Setctty, merged.Ctty = true, true, 0
+	merged.Setpgid, merged.Foreground = false, false
+	return &merged
+}
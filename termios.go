@@ -0,0 +1,48 @@
+package ptyx
+
+// Termios is a portable subset of the terminal line settings. Start from
+// DefaultTermios or RawTermios: control characters set to 0 are disabled and
+// Speed 0 leaves the baud rate unchanged.
+type Termios struct {
+	Echo        bool // ECHO
+	Canonical   bool // ICANON: line editing, input delivered per line
+	Signals     bool // ISIG: Intr, Quit and Susp raise signals
+	CRToNL      bool // ICRNL: translate input CR to NL
+	PostProcess bool // OPOST: enable output processing
+	NLToCRNL    bool // ONLCR: translate output NL to CR NL
+
+	Intr  byte
+	Quit  byte
+	Erase byte
+	Kill  byte
+	EOF   byte
+	Susp  byte
+
+	Speed int
+}
+
+// DefaultTermios matches the settings a fresh pty gets from the kernel.
+func DefaultTermios() Termios {
+	return Termios{
+		Echo:        true,
+		Canonical:   true,
+		Signals:     true,
+		CRToNL:      true,
+		PostProcess: true,
+		NLToCRNL:    true,
+		Intr:        0x03,
+		Quit:        0x1c,
+		Erase:       0x7f,
+		Kill:        0x15,
+		EOF:         0x04,
+		Susp:        0x1a,
+		Speed:       38400,
+	}
+}
+
+// RawTermios passes bytes through untouched in both directions.
+func RawTermios() Termios {
+	t := DefaultTermios()
+	t.Echo, t.Canonical, t.Signals, t.CRToNL, t.PostProcess, t.NLToCRNL = false, false, false, false, false, false
+	return t
+}
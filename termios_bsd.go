@@ -0,0 +1,24 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package ptyx
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+const posixVDisable = 0xff
+
+func termiosSpeed(t *unix.Termios) int { return int(t.Ospeed) }
+
+func setTermiosSpeed(t *unix.Termios, speed int) error {
+	if speed < 0 {
+		return fmt.Errorf("ptyx: unsupported baud rate %d", speed)
+	}
+	setSpeed(&t.Ispeed, speed)
+	setSpeed(&t.Ospeed, speed)
+	return nil
+}
+
+func setSpeed[T ~int32 | ~uint32 | ~uint64](f *T, speed int) { *f = T(speed) }
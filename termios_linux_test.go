@@ -0,0 +1,32 @@
+//go:build linux
+
+package ptyx
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestTermiosSpeed_Linux(t *testing.T) {
+	var tio unix.Termios
+	if err := setTermiosSpeed(&tio, 115200); err != nil {
+		t.Fatalf("setTermiosSpeed() failed: %v", err)
+	}
+	if got := termiosSpeed(&tio); got != 115200 {
+		t.Errorf("termiosSpeed() = %d, want 115200", got)
+	}
+	if err := setTermiosSpeed(&tio, 12345); err == nil {
+		t.Error("setTermiosSpeed(12345) should fail")
+	}
+	p, err := OpenPTY()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	bad := DefaultTermios()
+	bad.Speed = 12345
+	if err := setTermios(int(p.Slave().Fd()), bad); err == nil {
+		t.Error("setTermios() with an unsupported speed should fail")
+	}
+}
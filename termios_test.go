@@ -0,0 +1,13 @@
+package ptyx
+
+import "testing"
+
+func TestRawTermios(t *testing.T) {
+	raw := RawTermios()
+	if raw.Echo || raw.Canonical || raw.Signals || raw.CRToNL || raw.PostProcess || raw.NLToCRNL {
+		t.Errorf("RawTermios() = %+v, want every mode off", raw)
+	}
+	if raw.Intr != DefaultTermios().Intr || raw.Speed != DefaultTermios().Speed {
+		t.Errorf("RawTermios() should keep the default control characters and speed")
+	}
+}
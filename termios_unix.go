@@ -0,0 +1,137 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package ptyx
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var echoPollInterval = 50 * time.Millisecond
+
+func getTermios(fd int) (Termios, error) {
+	t, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return Termios{}, err
+	}
+	return Termios{
+		Echo:        t.Lflag&unix.ECHO != 0,
+		Canonical:   t.Lflag&unix.ICANON != 0,
+		Signals:     t.Lflag&unix.ISIG != 0,
+		CRToNL:      t.Iflag&unix.ICRNL != 0,
+		PostProcess: t.Oflag&unix.OPOST != 0,
+		NLToCRNL:    t.Oflag&unix.ONLCR != 0,
+		Intr:        controlChar(t.Cc[unix.VINTR]),
+		Quit:        controlChar(t.Cc[unix.VQUIT]),
+		Erase:       controlChar(t.Cc[unix.VERASE]),
+		Kill:        controlChar(t.Cc[unix.VKILL]),
+		EOF:         controlChar(t.Cc[unix.VEOF]),
+		Susp:        controlChar(t.Cc[unix.VSUSP]),
+		Speed:       termiosSpeed(t),
+	}, nil
+}
+
+func controlChar(c byte) byte {
+	if c == posixVDisable {
+		return 0
+	}
+	return c
+}
+
+func setTermios(fd int, tt Termios) error {
+	t, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return err
+	}
+	setFlag(&t.Lflag, unix.ECHO, tt.Echo)
+	setFlag(&t.Lflag, unix.ICANON, tt.Canonical)
+	setFlag(&t.Lflag, unix.ISIG, tt.Signals)
+	setFlag(&t.Iflag, unix.ICRNL, tt.CRToNL)
+	setFlag(&t.Oflag, unix.OPOST, tt.PostProcess)
+	setFlag(&t.Oflag, unix.ONLCR, tt.NLToCRNL)
+	for i, c := range map[int]byte{
+		unix.VINTR: tt.Intr, unix.VQUIT: tt.Quit, unix.VERASE: tt.Erase,
+		unix.VKILL: tt.Kill, unix.VEOF: tt.EOF, unix.VSUSP: tt.Susp,
+	} {
+		if c == 0 {
+			c = posixVDisable
+		}
+		t.Cc[i] = c
+	}
+	if !tt.Canonical {
+		t.Cc[unix.VMIN], t.Cc[unix.VTIME] = 1, 0
+	}
+	if tt.Speed != 0 {
+		if err := setTermiosSpeed(t, tt.Speed); err != nil {
+			return err
+		}
+	}
+	return unix.IoctlSetTermios(fd, ioctlSetTermios, t)
+}
+
+func setFlag[T ~uint32 | ~uint64](f *T, bit T, on bool) {
+	if on {
+		*f |= bit
+	} else {
+		*f &^= bit
+	}
+}
+
+func (s *unixSession) GetAttr() (Termios, error) { return getTermios(int(s.master.Fd())) }
+func (s *unixSession) SetAttr(t Termios) error   { return setTermios(int(s.master.Fd()), t) }
+
+// EchoEnabled fails closed: if the termios cannot be read, such as after
+// Close, input is treated as secret.
+func (s *unixSession) EchoEnabled() bool {
+	echo := false
+	_ = s.withMaster(func(fd int) error {
+		t, err := getTermios(fd)
+		echo = err == nil && t.Echo
+		return err
+	})
+	return echo
+}
+
+func (s *unixSession) OnEchoChange() <-chan bool {
+	s.echoOnce.Do(func() {
+		s.echoCh = make(chan bool, 1)
+		go s.watchEcho()
+	})
+	return s.echoCh
+}
+
+func (s *unixSession) watchEcho() {
+	defer close(s.echoCh)
+	var t Termios
+	read := func(fd int) (err error) {
+		t, err = getTermios(fd)
+		return err
+	}
+	if s.withMaster(read) != nil {
+		return
+	}
+	last := t.Echo
+	tick := time.NewTicker(echoPollInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.closing:
+			return
+		case <-tick.C:
+		}
+		if s.withMaster(read) != nil {
+			return
+		}
+		if t.Echo != last {
+			last = t.Echo
+			select {
+			case <-s.echoCh:
+			default:
+			}
+			s.echoCh <- last
+		}
+	}
+}
@@ -0,0 +1,163 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package ptyx
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTermios_RoundTrip(t *testing.T) {
+	p, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY() failed: %v", err)
+	}
+	defer p.Close()
+
+	want := RawTermios()
+	want.Erase = 0x08
+	want.Susp = 0
+	if err := setTermios(int(p.Slave().Fd()), want); err != nil {
+		t.Fatalf("setTermios() failed: %v", err)
+	}
+	got, err := getTermios(int(p.Slave().Fd()))
+	if err != nil {
+		t.Fatalf("getTermios() failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("getTermios() = %+v, want %+v", got, want)
+	}
+
+	if err := setTermios(int(p.Master().Fd()), DefaultTermios()); err != nil {
+		t.Fatalf("setTermios(default) failed: %v", err)
+	}
+	if got, _ := getTermios(int(p.Slave().Fd())); got != DefaultTermios() {
+		t.Errorf("getTermios() = %+v, want %+v", got, DefaultTermios())
+	}
+}
+
+func TestTermios_BadFd(t *testing.T) {
+	if _, err := getTermios(-1); err == nil {
+		t.Error("getTermios(-1) should fail")
+	}
+	if err := setTermios(-1, DefaultTermios()); err == nil {
+		t.Error("setTermios(-1) should fail")
+	}
+}
+
+func TestSpawn_Termios(t *testing.T) {
+	tt := DefaultTermios()
+	tt.Echo = false
+	tt.NLToCRNL = false
+	s, err := Spawn(context.Background(), SpawnOpts{
+		Prog:    "sh",
+		Args:    []string{"-c", `printf 'a\nb\n'`},
+		Termios: &tt,
+	})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	defer s.Close()
+
+	got, err := s.GetAttr()
+	if err != nil {
+		t.Fatalf("GetAttr() failed: %v", err)
+	}
+	if got.Echo || got.NLToCRNL || !got.Canonical {
+		t.Errorf("GetAttr() = %+v, want the spawn profile", got)
+	}
+
+	out := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(s.PtyReader())
+		out <- b
+	}()
+	_ = waitTimeout(t, s, 3*time.Second)
+	select {
+	case b := <-out:
+		if string(b) != "a\nb\n" {
+			t.Errorf("output = %q, want byte-exact %q", b, "a\nb\n")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("output not drained")
+	}
+}
+
+func TestUnixSession_SetAttr(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo ready; sleep 1"}})
+	defer s.Kill()
+
+	tt := RawTermios()
+	if err := s.SetAttr(tt); err != nil {
+		t.Fatalf("SetAttr() failed: %v", err)
+	}
+	if got, err := s.GetAttr(); err != nil || got != tt {
+		t.Errorf("GetAttr() = %+v, %v; want %+v", got, err, tt)
+	}
+}
+
+func TestUnixSession_OnEchoChange(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{
+		Prog: "sh",
+		Args: []string{"-c", "echo ready; read x; stty -echo; read x; stty echo; read x"},
+	})
+	defer s.Kill()
+
+	if !s.EchoEnabled() {
+		t.Fatal("EchoEnabled() = false before the child changed anything")
+	}
+	ch := s.OnEchoChange()
+	if s.OnEchoChange() != ch {
+		t.Error("OnEchoChange() should return the same channel")
+	}
+
+	next := func(want bool) {
+		t.Helper()
+		select {
+		case got := <-ch:
+			if got != want {
+				t.Fatalf("echo change = %v, want %v", got, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no echo change to %v", want)
+		}
+		if s.EchoEnabled() != want {
+			t.Errorf("EchoEnabled() = %v, want %v", s.EchoEnabled(), want)
+		}
+	}
+
+	_, _ = s.PtyWriter().Write([]byte("go\n"))
+	next(false)
+	_, _ = s.PtyWriter().Write([]byte("secret\n"))
+	next(true)
+	_, _ = s.PtyWriter().Write([]byte("done\n"))
+
+	_ = waitTimeout(t, s, 3*time.Second)
+	select {
+	case _, ok := <-ch:
+		if ok {
+			for range ch {
+			}
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnEchoChange() channel not closed after exit")
+	}
+}
+
+func TestUnixSession_EchoAfterClose(t *testing.T) {
+	s := spawnReady(t, context.Background(), SpawnOpts{Prog: "sh", Args: []string{"-c", "echo ready; sleep 10"}})
+	ch := s.OnEchoChange()
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if s.EchoEnabled() {
+		t.Error("EchoEnabled() = true after Close, want false")
+	}
+	select {
+	case <-ch:
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnEchoChange() channel not closed after Close")
+	}
+}
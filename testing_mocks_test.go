@@ -2,8 +2,10 @@ package ptyx
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
+	"sync"
 )
 
 type mockConsole struct {
@@ -36,9 +38,14 @@ func (m *mockConsole) Close() error              { return m.in.Close() }
 type mockSession struct {
 	ptyIn  *bytes.Buffer
 	ptyOut io.Reader
+	events chan PacketEvent
 	closeStdinFunc func() error
 	waitFunc       func() error
 	closeFunc      func() error
+
+	waitOnce sync.Once
+	done     chan struct{}
+	waitErr  error
 }
 
 func newMockSession(output string) *mockSession {
@@ -51,11 +58,45 @@ func newMockSession(output string) *mockSession {
 func (m *mockSession) PtyReader() io.Reader      { return m.ptyOut }
 func (m *mockSession) PtyWriter() io.Writer      { return m.ptyIn }
 func (m *mockSession) Resize(cols, rows int) error { return nil }
+
+// start runs waitFunc once in the background, the way a real session reaps
+// its child, and closes done with the result.
+func (m *mockSession) start() {
+	m.waitOnce.Do(func() {
+		m.done = make(chan struct{})
+		go func() {
+			if m.waitFunc != nil {
+				m.waitErr = m.waitFunc()
+			}
+			close(m.done)
+		}()
+	})
+}
 func (m *mockSession) Wait() error {
-	if m.waitFunc != nil {
-		return m.waitFunc()
+	m.start()
+	<-m.done
+	return m.waitErr
+}
+func (m *mockSession) Done() <-chan struct{} {
+	m.start()
+	return m.done
+}
+func (m *mockSession) WaitContext(ctx context.Context) error { return waitContext(ctx, m) }
+func (m *mockSession) ExitStatus() (int, bool) {
+	if !isDone(m.Done()) {
+		return 0, false
 	}
-	return nil
+	return exitCode(m.waitErr), true
+}
+func (m *mockSession) Result() (Result, bool) {
+	code, exited := m.ExitStatus()
+	return Result{ExitCode: code}, exited
+}
+func (m *mockSession) State() SessionState {
+	if isDone(m.Done()) {
+		return StateExited
+	}
+	return StateRunning
 }
 func (m *mockSession) Kill() error                 { return nil }
 func (m *mockSession) Close() error {
@@ -64,7 +105,20 @@ func (m *mockSession) Close() error {
 	}
 	return nil
 }
-func (m *mockSession) Pid() int                    { return 1234 }
+func (m *mockSession) Pid() int                      { return 1234 }
+func (m *mockSession) Signal(Signal) error           { return nil }
+func (m *mockSession) SignalForeground(Signal) error { return nil }
+func (m *mockSession) Interrupt(InterruptMode) error { return nil }
+func (m *mockSession) GetAttr() (Termios, error)     { return DefaultTermios(), nil }
+func (m *mockSession) SetAttr(Termios) error         { return nil }
+func (m *mockSession) EchoEnabled() bool             { return true }
+func (m *mockSession) OnEchoChange() <-chan bool     { return nil }
+func (m *mockSession) PacketEvents() <-chan PacketEvent {
+	if m.events == nil {
+		return nil
+	}
+	return m.events
+}
 func (m *mockSession) CloseStdin() error {
 	if m.closeStdinFunc != nil {
 		return m.closeStdinFunc()
@@ -2,8 +2,11 @@ package testptyx
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"os"
+	"sync"
 
 	"github.com/safedep/ptyx"
 )
@@ -52,12 +55,32 @@ type MockSession struct {
 	PtyOutReader    io.Reader
 	WaitError       error
 	ForceWriteError error
+	SignalError     error
+	Termios         ptyx.Termios
+	AttrError       error
+	EchoChanges     chan bool
+	Packets         chan ptyx.PacketEvent
+	Sandbox         *ptyx.SandboxReport
+	Cgroup          *ptyx.CgroupStats
+	// Exited, when set, holds Wait and Done until it is closed; otherwise the
+	// mock process has already exited with WaitError.
+	Exited chan struct{}
+	// Usage is what Result reports once the mock process has exited; its
+	// ExitCode is taken from WaitError.
+	Usage ptyx.Result
+
+	mu                sync.Mutex
+	signals           []ptyx.Signal
+	foregroundSignals []ptyx.Signal
+	interrupts        []ptyx.InterruptMode
+	closed            bool
 }
 
 func NewMockSession(output string) *MockSession {
 	return &MockSession{
 		PtyInBuffer:  &bytes.Buffer{},
 		PtyOutReader: bytes.NewBufferString(output),
+		Termios:      ptyx.DefaultTermios(),
 	}
 }
 
@@ -70,13 +93,168 @@ func (m *MockSession) PtyWriter() io.Writer {
 }
 func (m *MockSession) Resize(cols, rows int) error { return nil }
 func (m *MockSession) Wait() error {
+	<-m.Done()
 	return m.WaitError
 }
-func (m *MockSession) Kill() error       { return nil }
-func (m *MockSession) Close() error      { return nil }
+
+func (m *MockSession) Done() <-chan struct{} {
+	if m.Exited != nil {
+		return m.Exited
+	}
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+func (m *MockSession) WaitContext(ctx context.Context) error {
+	select {
+	case <-m.Done():
+		return m.WaitError
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *MockSession) ExitStatus() (int, bool) {
+	select {
+	case <-m.Done():
+	default:
+		return 0, false
+	}
+	var ee *ptyx.ExitError
+	switch {
+	case m.WaitError == nil:
+		return 0, true
+	case errors.As(m.WaitError, &ee):
+		return ee.ExitCode, true
+	}
+	return -1, true
+}
+
+func (m *MockSession) Result() (ptyx.Result, bool) {
+	code, exited := m.ExitStatus()
+	if !exited {
+		return ptyx.Result{}, false
+	}
+	r := m.Usage
+	r.ExitCode = code
+	return r, true
+}
+
+func (m *MockSession) State() ptyx.SessionState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ptyx.StateClosed
+	}
+	select {
+	case <-m.Done():
+		return ptyx.StateExited
+	default:
+		return ptyx.StateRunning
+	}
+}
+
+func (m *MockSession) Kill() error { return nil }
+func (m *MockSession) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	return nil
+}
 func (m *MockSession) Pid() int          { return 1234 }
 func (m *MockSession) CloseStdin() error { return nil }
 
+func (m *MockSession) Signal(sig ptyx.Signal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signals = append(m.signals, sig)
+	return m.SignalError
+}
+
+func (m *MockSession) SignalForeground(sig ptyx.Signal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.foregroundSignals = append(m.foregroundSignals, sig)
+	return m.SignalError
+}
+
+func (m *MockSession) Interrupt(mode ptyx.InterruptMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.interrupts = append(m.interrupts, mode)
+	return m.SignalError
+}
+
+func (m *MockSession) GetAttr() (ptyx.Termios, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Termios, m.AttrError
+}
+
+func (m *MockSession) SetAttr(t ptyx.Termios) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.AttrError != nil {
+		return m.AttrError
+	}
+	m.Termios = t
+	return nil
+}
+
+func (m *MockSession) EchoEnabled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Termios.Echo
+}
+
+func (m *MockSession) OnEchoChange() <-chan bool { return m.EchoChanges }
+
+func (m *MockSession) PacketEvents() <-chan ptyx.PacketEvent {
+	if m.Packets == nil {
+		return nil
+	}
+	return m.Packets
+}
+
+func (m *MockSession) ExitFd() (uintptr, error) { return 0, ptyx.ErrUnsupported }
+
+func (m *MockSession) SandboxReport() *ptyx.SandboxReport { return m.Sandbox }
+
+func (m *MockSession) CgroupStats() (ptyx.CgroupStats, error) {
+	if m.Cgroup == nil {
+		return ptyx.CgroupStats{}, ptyx.ErrCgroupUnavailable
+	}
+	return *m.Cgroup, nil
+}
+
+func (m *MockSession) SetEcho(on bool) {
+	m.mu.Lock()
+	m.Termios.Echo = on
+	m.mu.Unlock()
+	if m.EchoChanges != nil {
+		m.EchoChanges <- on
+	}
+}
+
+func (m *MockSession) Signals() []ptyx.Signal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ptyx.Signal(nil), m.signals...)
+}
+
+func (m *MockSession) ForegroundSignals() []ptyx.Signal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ptyx.Signal(nil), m.foregroundSignals...)
+}
+
+func (m *MockSession) Interrupts() []ptyx.InterruptMode {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ptyx.InterruptMode(nil), m.interrupts...)
+}
+
 type errorWriter struct {
 	err error
 }
@@ -1,10 +1,14 @@
 package testptyx
 
 import (
+	"context"
 	"errors"
 	"io"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/safedep/ptyx"
 )
 
 func TestMockConsole(t *testing.T) {
@@ -171,3 +175,147 @@ func TestMockSession(t *testing.T) {
 		}
 	})
 }
+
+func TestMockSession_Signals(t *testing.T) {
+	ms := NewMockSession("")
+	_ = ms.Signal(ptyx.SIGTERM)
+	_ = ms.SignalForeground(ptyx.SIGWINCH)
+	_ = ms.Interrupt(ptyx.InterruptByChar)
+	if got := ms.Signals(); len(got) != 1 || got[0] != ptyx.SIGTERM {
+		t.Errorf("Signals() = %v", got)
+	}
+	if got := ms.ForegroundSignals(); len(got) != 1 || got[0] != ptyx.SIGWINCH {
+		t.Errorf("ForegroundSignals() = %v", got)
+	}
+	if got := ms.Interrupts(); len(got) != 1 || got[0] != ptyx.InterruptByChar {
+		t.Errorf("Interrupts() = %v", got)
+	}
+
+	ms.SignalError = errors.New("no such process")
+	if ms.Signal(ptyx.SIGINT) == nil || ms.SignalForeground(ptyx.SIGINT) == nil || ms.Interrupt(ptyx.InterruptBySignal) == nil {
+		t.Error("SignalError should be returned")
+	}
+}
+
+func TestMockSession_Attr(t *testing.T) {
+	ms := NewMockSession("")
+	if got, _ := ms.GetAttr(); got != ptyx.DefaultTermios() {
+		t.Errorf("GetAttr() = %+v, want defaults", got)
+	}
+	raw := ptyx.RawTermios()
+	if err := ms.SetAttr(raw); err != nil {
+		t.Fatalf("SetAttr() failed: %v", err)
+	}
+	if got, _ := ms.GetAttr(); got != raw {
+		t.Errorf("GetAttr() = %+v, want %+v", got, raw)
+	}
+	ms.AttrError = errors.New("not a tty")
+	if ms.SetAttr(raw) == nil {
+		t.Error("SetAttr() should return AttrError")
+	}
+}
+
+func TestMockSession_Echo(t *testing.T) {
+	ms := NewMockSession("")
+	if !ms.EchoEnabled() || ms.OnEchoChange() != nil {
+		t.Fatal("echo should start enabled with no change channel")
+	}
+	ms.EchoChanges = make(chan bool, 1)
+	ms.SetEcho(false)
+	if ms.EchoEnabled() {
+		t.Error("EchoEnabled() = true after SetEcho(false)")
+	}
+	if got := <-ms.OnEchoChange(); got {
+		t.Error("OnEchoChange() delivered true, want false")
+	}
+}
+
+func TestMockSession_PacketEvents(t *testing.T) {
+	ms := NewMockSession("")
+	if ms.PacketEvents() != nil {
+		t.Fatal("PacketEvents() should be nil by default")
+	}
+	ms.Packets = make(chan ptyx.PacketEvent, 1)
+	ms.Packets <- ptyx.PacketStop
+	if got := <-ms.PacketEvents(); got != ptyx.PacketStop {
+		t.Errorf("PacketEvents() delivered %v, want STOP", got)
+	}
+	if _, err := ms.ExitFd(); !errors.Is(err, ptyx.ErrUnsupported) {
+		t.Errorf("ExitFd() = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestMockSession_SandboxReport(t *testing.T) {
+	ms := NewMockSession("")
+	if ms.SandboxReport() != nil {
+		t.Fatal("SandboxReport() should be nil by default")
+	}
+	ms.Sandbox = &ptyx.SandboxReport{NoNewPrivs: true}
+	if rep := ms.SandboxReport(); rep == nil || !rep.NoNewPrivs {
+		t.Errorf("SandboxReport() = %+v, want the configured report", rep)
+	}
+}
+
+func TestMockSession_CgroupStats(t *testing.T) {
+	ms := NewMockSession("")
+	if _, err := ms.CgroupStats(); !errors.Is(err, ptyx.ErrCgroupUnavailable) {
+		t.Fatalf("CgroupStats() = %v, want ErrCgroupUnavailable by default", err)
+	}
+	ms.Cgroup = &ptyx.CgroupStats{Pids: 3}
+	if st, err := ms.CgroupStats(); err != nil || st.Pids != 3 {
+		t.Errorf("CgroupStats() = %+v, %v; want the configured stats", st, err)
+	}
+}
+
+func TestMockSession_Done(t *testing.T) {
+	ms := NewMockSession("")
+	ms.WaitError = &ptyx.ExitError{ExitCode: 5}
+	ms.Exited = make(chan struct{})
+	if st := ms.State(); st != ptyx.StateRunning {
+		t.Errorf("State() = %v before Exited is closed, want running", st)
+	}
+	if _, exited := ms.ExitStatus(); exited {
+		t.Error("ExitStatus() reported an exit before Exited is closed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ms.WaitContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitContext() = %v, want context.Canceled", err)
+	}
+
+	close(ms.Exited)
+	<-ms.Done()
+	if err := ms.Wait(); err != ms.WaitError {
+		t.Errorf("Wait() = %v, want WaitError", err)
+	}
+	if code, exited := ms.ExitStatus(); code != 5 || !exited {
+		t.Errorf("ExitStatus() = %d, %v; want 5, true", code, exited)
+	}
+	if st := ms.State(); st != ptyx.StateExited {
+		t.Errorf("State() = %v, want exited", st)
+	}
+	_ = ms.Close()
+	if st := ms.State(); st != ptyx.StateClosed {
+		t.Errorf("State() = %v after Close, want closed", st)
+	}
+
+	ms = NewMockSession("")
+	ms.WaitError = errors.New("boom")
+	if code, exited := ms.ExitStatus(); code != -1 || !exited {
+		t.Errorf("ExitStatus() = %d, %v; want -1, true without Exited", code, exited)
+	}
+}
+
+func TestMockSession_Result(t *testing.T) {
+	ms := NewMockSession("")
+	ms.Exited = make(chan struct{})
+	ms.WaitError = &ptyx.ExitError{ExitCode: 1}
+	ms.Usage = ptyx.Result{MaxRSS: 4096, UserTime: time.Second}
+	if _, ok := ms.Result(); ok {
+		t.Error("Result() reported a result before Exited is closed")
+	}
+	close(ms.Exited)
+	if r, ok := ms.Result(); !ok || r.ExitCode != 1 || r.MaxRSS != 4096 || r.UserTime != time.Second {
+		t.Errorf("Result() = %+v, %v; want Usage with the exit code", r, ok)
+	}
+}
@@ -0,0 +1,805 @@
+// Package vt keeps an in-memory model of a VT100/xterm screen that is
+// updated from the output of a PTY session.
+package vt
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/safedep/ptyx/vtparse"
+)
+
+type ColorKind uint8
+
+const (
+	ColorDefault ColorKind = iota
+	ColorIndexed
+	ColorRGB
+)
+
+type Color struct {
+	Kind    ColorKind
+	Index   uint8
+	R, G, B uint8
+}
+
+type Attr struct {
+	FG, BG    Color
+	Bold      bool
+	Faint     bool
+	Italic    bool
+	Underline bool
+	Blink     bool
+	Inverse   bool
+	Hidden    bool
+	Strike    bool
+}
+
+// Cell is one screen position. A wide rune such as 日 fills two: its own
+// cell and a continuation cell after it whose Rune is 0.
+type Cell struct {
+	Rune rune
+	Attr Attr
+}
+
+var blankCell = Cell{Rune: ' '}
+
+type cursor struct {
+	x, y   int
+	attr   Attr
+	origin bool
+}
+
+type Screen struct {
+	mu sync.Mutex
+
+	cols, rows int
+	primary    [][]Cell
+	alternate  [][]Cell
+	grid       [][]Cell
+	wrapped    []bool
+	altActive  bool
+
+	cur      cursor
+	saved    cursor
+	altSaved cursor
+	wrapNext bool
+	autoWrap bool
+	visible  bool
+	top, bot int
+	tabs     []bool
+	title    string
+	parser   *vtparse.Parser
+}
+
+func NewScreen(cols, rows int) *Screen {
+	if cols <= 0 {
+		cols = 80
+	}
+	if rows <= 0 {
+		rows = 24
+	}
+	s := &Screen{}
+	s.parser = vtparse.New(s.handle)
+	s.init(cols, rows)
+	return s
+}
+
+func (s *Screen) init(cols, rows int) {
+	s.cols, s.rows = cols, rows
+	s.primary = newGrid(cols, rows)
+	s.alternate = newGrid(cols, rows)
+	s.grid = s.primary
+	s.wrapped = make([]bool, rows)
+	s.altActive = false
+	s.cur = cursor{}
+	s.saved = cursor{}
+	s.altSaved = cursor{}
+	s.wrapNext = false
+	s.autoWrap = true
+	s.visible = true
+	s.top, s.bot = 0, rows-1
+	s.resetTabs()
+}
+
+func newGrid(cols, rows int) [][]Cell {
+	g := make([][]Cell, rows)
+	for y := range g {
+		g[y] = newLine(cols)
+	}
+	return g
+}
+
+func newLine(cols int) []Cell {
+	l := make([]Cell, cols)
+	for x := range l {
+		l[x] = blankCell
+	}
+	return l
+}
+
+func (s *Screen) resetTabs() {
+	s.tabs = make([]bool, s.cols)
+	for x := 8; x < s.cols; x += 8 {
+		s.tabs[x] = true
+	}
+}
+
+func (s *Screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.parser.Write(p)
+}
+
+func (s *Screen) Resize(cols, rows int) {
+	if cols <= 0 || rows <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cols == s.cols && rows == s.rows {
+		return
+	}
+	s.primary = resizeGrid(s.primary, cols, rows)
+	s.alternate = resizeGrid(s.alternate, cols, rows)
+	if s.altActive {
+		s.grid = s.alternate
+	} else {
+		s.grid = s.primary
+	}
+	w := make([]bool, rows)
+	copy(w, s.wrapped)
+	s.wrapped = w
+	s.cols, s.rows = cols, rows
+	s.top, s.bot = 0, rows-1
+	s.cur.x, s.cur.y = clamp(s.cur.x, 0, cols-1), clamp(s.cur.y, 0, rows-1)
+	s.wrapNext = false
+	s.resetTabs()
+}
+
+func resizeGrid(g [][]Cell, cols, rows int) [][]Cell {
+	out := newGrid(cols, rows)
+	for y := 0; y < rows && y < len(g); y++ {
+		copy(out[y], g[y])
+	}
+	return out
+}
+
+func (s *Screen) Size() (cols, rows int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cols, s.rows
+}
+
+func (s *Screen) Cursor() (x, y int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur.x, s.cur.y
+}
+
+func (s *Screen) CursorVisible() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.visible
+}
+
+func (s *Screen) AltScreen() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.altActive
+}
+
+func (s *Screen) ScrollRegion() (top, bottom int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.top, s.bot
+}
+
+func (s *Screen) Title() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.title
+}
+
+func (s *Screen) Wrapped(y int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if y < 0 || y >= s.rows {
+		return false
+	}
+	return s.wrapped[y]
+}
+
+func (s *Screen) Cell(x, y int) Cell {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if x < 0 || y < 0 || x >= s.cols || y >= s.rows {
+		return blankCell
+	}
+	return s.grid[y][x]
+}
+
+func (s *Screen) Cells() [][]Cell {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([][]Cell, s.rows)
+	for y := range out {
+		out[y] = append([]Cell(nil), s.grid[y]...)
+	}
+	return out
+}
+
+func (s *Screen) Lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, s.rows)
+	var b strings.Builder
+	for y, line := range s.grid {
+		b.Reset()
+		for _, c := range line {
+			if c.Rune != 0 {
+				b.WriteRune(c.Rune)
+			}
+		}
+		out[y] = strings.TrimRight(b.String(), " ")
+	}
+	return out
+}
+
+func (s *Screen) String() string {
+	lines := s.Lines()
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	return strings.Join(lines[:end], "\n")
+}
+
+func (s *Screen) handle(e vtparse.Event) {
+	switch e := e.(type) {
+	case vtparse.Print:
+		for _, r := range e.Text {
+			s.print(r)
+		}
+	case vtparse.Execute:
+		s.execute(e.Code)
+	case vtparse.CSI:
+		s.csiDispatch(e)
+	case vtparse.ESC:
+		s.escDispatch(e.Intermediates, e.Final)
+	case vtparse.SingleShift:
+		// G2 and G3 are not tracked, so the shifted character prints as is.
+		s.print(e.Char)
+	case vtparse.OSC:
+		s.oscDispatch(e)
+	}
+}
+
+func (s *Screen) print(r rune) {
+	if s.wrapNext {
+		if s.autoWrap {
+			s.wrapped[s.cur.y] = true
+			s.cur.x = 0
+			s.lineFeed()
+		}
+		s.wrapNext = false
+	}
+	w := runeWidth(r)
+	if w == 2 && s.cols < 2 {
+		w = 1
+	}
+	if w == 2 && s.cur.x == s.cols-1 {
+		if s.autoWrap {
+			s.splitWide(s.grid[s.cur.y], s.cur.x)
+			s.grid[s.cur.y][s.cur.x] = s.blank()
+			s.wrapped[s.cur.y] = true
+			s.cur.x = 0
+			s.lineFeed()
+		} else {
+			s.cur.x--
+		}
+	}
+	line := s.grid[s.cur.y]
+	for x := s.cur.x; x < s.cur.x+w; x++ {
+		s.splitWide(line, x)
+	}
+	line[s.cur.x] = Cell{Rune: r, Attr: s.cur.attr}
+	if w == 2 {
+		line[s.cur.x+1] = Cell{Attr: s.cur.attr}
+	}
+	if s.cur.x+w > s.cols-1 {
+		s.cur.x = s.cols - 1
+		s.wrapNext = true
+	} else {
+		s.cur.x += w
+	}
+}
+
+// splitWide blanks the other half of a wide rune that a write to line[x]
+// is about to break apart.
+func (s *Screen) splitWide(line []Cell, x int) {
+	if line[x].Rune == 0 && x > 0 {
+		line[x-1] = s.blank()
+	}
+	if x+1 < len(line) && line[x+1].Rune == 0 {
+		line[x+1] = s.blank()
+	}
+}
+
+func (s *Screen) execute(b byte) {
+	switch b {
+	case '\b':
+		s.wrapNext = false
+		if s.cur.x > 0 {
+			s.cur.x--
+		}
+	case '\t':
+		s.tab(1)
+	case '\n', '\v', '\f':
+		s.lineFeed()
+	case '\r':
+		s.wrapNext = false
+		s.cur.x = 0
+	}
+}
+
+func (s *Screen) tab(n int) {
+	s.wrapNext = false
+	for ; n > 0 && s.cur.x < s.cols-1; n-- {
+		s.cur.x++
+		for s.cur.x < s.cols-1 && !s.tabs[s.cur.x] {
+			s.cur.x++
+		}
+	}
+}
+
+func (s *Screen) lineFeed() {
+	s.wrapNext = false
+	switch {
+	case s.cur.y == s.bot:
+		s.scrollUp(s.top, s.bot, 1)
+	case s.cur.y < s.rows-1:
+		s.cur.y++
+	}
+}
+
+func (s *Screen) reverseIndex() {
+	s.wrapNext = false
+	switch {
+	case s.cur.y == s.top:
+		s.scrollDown(s.top, s.bot, 1)
+	case s.cur.y > 0:
+		s.cur.y--
+	}
+}
+
+func (s *Screen) scrollUp(top, bot, n int) {
+	n = min(n, bot-top+1)
+	copy(s.grid[top:bot+1], s.grid[top+n:bot+1])
+	copy(s.wrapped[top:bot+1], s.wrapped[top+n:bot+1])
+	for y := bot - n + 1; y <= bot; y++ {
+		s.grid[y] = s.blankLine()
+		s.wrapped[y] = false
+	}
+}
+
+func (s *Screen) scrollDown(top, bot, n int) {
+	n = min(n, bot-top+1)
+	copy(s.grid[top+n:bot+1], s.grid[top:bot+1-n])
+	copy(s.wrapped[top+n:bot+1], s.wrapped[top:bot+1-n])
+	for y := top; y < top+n; y++ {
+		s.grid[y] = s.blankLine()
+		s.wrapped[y] = false
+	}
+}
+
+func (s *Screen) blankLine() []Cell {
+	l := make([]Cell, s.cols)
+	for x := range l {
+		l[x] = s.blank()
+	}
+	return l
+}
+
+func (s *Screen) blank() Cell {
+	return Cell{Rune: ' ', Attr: Attr{BG: s.cur.attr.BG}}
+}
+
+func (s *Screen) clearCells(y, from, to int) {
+	for x := max(from, 0); x < to && x < s.cols; x++ {
+		s.grid[y][x] = s.blank()
+	}
+}
+
+func (s *Screen) moveTo(x, y int) {
+	s.wrapNext = false
+	if s.cur.origin {
+		y += s.top
+		s.cur.y = clamp(y, s.top, s.bot)
+	} else {
+		s.cur.y = clamp(y, 0, s.rows-1)
+	}
+	s.cur.x = clamp(x, 0, s.cols-1)
+}
+
+func (s *Screen) saveCursor() { s.saved = s.cur }
+
+func (s *Screen) restoreCursor() {
+	s.cur = s.saved
+	s.cur.x = clamp(s.cur.x, 0, s.cols-1)
+	s.cur.y = clamp(s.cur.y, 0, s.rows-1)
+	s.wrapNext = false
+}
+
+func (s *Screen) setAltScreen(on, saveCursor, clearAlt bool) {
+	if on == s.altActive {
+		return
+	}
+	if on {
+		if saveCursor {
+			s.altSaved = s.cur
+		}
+		s.altActive = true
+		s.grid = s.alternate
+		if clearAlt {
+			for y := range s.grid {
+				s.grid[y] = newLine(s.cols)
+			}
+		}
+	} else {
+		s.altActive = false
+		s.grid = s.primary
+		if saveCursor {
+			s.cur = s.altSaved
+			s.cur.x = clamp(s.cur.x, 0, s.cols-1)
+			s.cur.y = clamp(s.cur.y, 0, s.rows-1)
+		}
+	}
+	for y := range s.wrapped {
+		s.wrapped[y] = false
+	}
+	s.wrapNext = false
+}
+
+func (s *Screen) escDispatch(intermediates []byte, final byte) {
+	if len(intermediates) > 0 {
+		if intermediates[0] == '#' && final == '8' {
+			for y := range s.grid {
+				for x := range s.grid[y] {
+					s.grid[y][x] = Cell{Rune: 'E'}
+				}
+			}
+		}
+		return
+	}
+	switch final {
+	case '7':
+		s.saveCursor()
+	case '8':
+		s.restoreCursor()
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.cur.x = 0
+		s.lineFeed()
+	case 'H':
+		if s.cur.x < s.cols {
+			s.tabs[s.cur.x] = true
+		}
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.init(s.cols, s.rows)
+		s.title = ""
+	}
+}
+
+func (s *Screen) oscDispatch(e vtparse.OSC) {
+	cmd, arg := e.Command()
+	switch cmd {
+	case "0", "2":
+		s.title = arg
+	}
+}
+
+func param(params []int, i, def int) int {
+	if i >= len(params) || params[i] <= 0 {
+		return def
+	}
+	return params[i]
+}
+
+func (s *Screen) csiDispatch(e vtparse.CSI) {
+	params := e.Params
+	if e.Private == '?' {
+		switch e.Final {
+		case 'h', 'l':
+			s.setPrivateModes(params, e.Final == 'h')
+		}
+		return
+	}
+	if e.Private != 0 || len(e.Intermediates) > 0 {
+		return
+	}
+
+	n := e.Param(0, 1)
+	switch e.Final {
+	case 'A':
+		s.moveRel(0, -n)
+	case 'B', 'e':
+		s.moveRel(0, n)
+	case 'C', 'a':
+		s.moveRel(n, 0)
+	case 'D':
+		s.moveRel(-n, 0)
+	case 'E':
+		s.moveRel(0, n)
+		s.cur.x = 0
+	case 'F':
+		s.moveRel(0, -n)
+		s.cur.x = 0
+	case 'G', '`':
+		s.wrapNext = false
+		s.cur.x = clamp(n-1, 0, s.cols-1)
+	case 'H', 'f':
+		s.moveTo(param(params, 1, 1)-1, n-1)
+	case 'd':
+		s.wrapNext = false
+		s.moveTo(s.cur.x, n-1)
+	case 'I':
+		s.tab(n)
+	case 'J':
+		s.eraseDisplay(param(params, 0, 0))
+	case 'K':
+		s.eraseLine(param(params, 0, 0))
+	case 'L':
+		if s.cur.y >= s.top && s.cur.y <= s.bot {
+			s.scrollDown(s.cur.y, s.bot, n)
+			s.cur.x = 0
+		}
+	case 'M':
+		if s.cur.y >= s.top && s.cur.y <= s.bot {
+			s.scrollUp(s.cur.y, s.bot, n)
+			s.cur.x = 0
+		}
+	case '@':
+		line := s.grid[s.cur.y]
+		n = min(n, s.cols-s.cur.x)
+		copy(line[s.cur.x+n:], line[s.cur.x:])
+		s.clearCells(s.cur.y, s.cur.x, s.cur.x+n)
+	case 'P':
+		line := s.grid[s.cur.y]
+		n = min(n, s.cols-s.cur.x)
+		copy(line[s.cur.x:], line[s.cur.x+n:])
+		s.clearCells(s.cur.y, s.cols-n, s.cols)
+	case 'X':
+		s.clearCells(s.cur.y, s.cur.x, s.cur.x+n)
+	case 'S':
+		s.scrollUp(s.top, s.bot, n)
+	case 'T':
+		s.scrollDown(s.top, s.bot, n)
+	case 'g':
+		switch param(params, 0, 0) {
+		case 0:
+			s.tabs[s.cur.x] = false
+		case 3:
+			s.tabs = make([]bool, s.cols)
+		}
+	case 'm':
+		s.sgr(params, e.SubParams)
+	case 'r':
+		top, bot := param(params, 0, 1)-1, param(params, 1, s.rows)-1
+		if bot >= s.rows {
+			bot = s.rows - 1
+		}
+		if top < bot {
+			s.top, s.bot = top, bot
+			s.moveTo(0, 0)
+		}
+	case 's':
+		s.saveCursor()
+	case 'u':
+		s.restoreCursor()
+	}
+}
+
+func (s *Screen) moveRel(dx, dy int) {
+	s.wrapNext = false
+	top, bot := 0, s.rows-1
+	if s.cur.y >= s.top && s.cur.y <= s.bot {
+		top, bot = s.top, s.bot
+	}
+	s.cur.x = clamp(s.cur.x+dx, 0, s.cols-1)
+	s.cur.y = clamp(s.cur.y+dy, top, bot)
+}
+
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.clearCells(s.cur.y, s.cur.x, s.cols)
+		for y := s.cur.y + 1; y < s.rows; y++ {
+			s.clearCells(y, 0, s.cols)
+			s.wrapped[y] = false
+		}
+	case 1:
+		s.clearCells(s.cur.y, 0, s.cur.x+1)
+		for y := 0; y < s.cur.y; y++ {
+			s.clearCells(y, 0, s.cols)
+			s.wrapped[y] = false
+		}
+	case 2, 3:
+		for y := 0; y < s.rows; y++ {
+			s.clearCells(y, 0, s.cols)
+			s.wrapped[y] = false
+		}
+	}
+}
+
+func (s *Screen) eraseLine(mode int) {
+	switch mode {
+	case 0:
+		s.clearCells(s.cur.y, s.cur.x, s.cols)
+		s.wrapped[s.cur.y] = false
+	case 1:
+		s.clearCells(s.cur.y, 0, s.cur.x+1)
+	case 2:
+		s.clearCells(s.cur.y, 0, s.cols)
+		s.wrapped[s.cur.y] = false
+	}
+}
+
+func (s *Screen) setPrivateModes(params []int, on bool) {
+	for _, p := range params {
+		switch p {
+		case 6:
+			s.cur.origin = on
+			s.moveTo(0, 0)
+		case 7:
+			s.autoWrap = on
+			if !on {
+				s.wrapNext = false
+			}
+		case 25:
+			s.visible = on
+		case 47, 1047:
+			s.setAltScreen(on, false, p == 1047 && on)
+		case 1048:
+			if on {
+				s.saveCursor()
+			} else {
+				s.restoreCursor()
+			}
+		case 1049:
+			s.setAltScreen(on, true, on)
+		}
+	}
+}
+
+// sgr applies Select Graphic Rendition. sub marks the parameters that
+// followed a ':' and so belong to the code before them, as in 4:3 (curly
+// underline) or 38:2::255:0:0; they are never read as codes of their own.
+func (s *Screen) sgr(params []int, sub uint32) {
+	if len(params) == 0 {
+		s.cur.attr = Attr{}
+		return
+	}
+	a := &s.cur.attr
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		n := 0
+		for j := i + 1; j < len(params) && j < 32 && sub&(1<<j) != 0; j++ {
+			n++
+		}
+		subs := params[i+1 : i+1+n]
+		switch {
+		case p <= 0:
+			*a = Attr{}
+		case p == 1:
+			a.Bold = true
+		case p == 2:
+			a.Faint = true
+		case p == 3:
+			a.Italic = true
+		case p == 4:
+			// 4:0 turns underline off; any other style is drawn as underline.
+			a.Underline = n == 0 || subs[0] > 0
+		case p == 5 || p == 6:
+			a.Blink = true
+		case p == 7:
+			a.Inverse = true
+		case p == 8:
+			a.Hidden = true
+		case p == 9:
+			a.Strike = true
+		case p == 21 || p == 24:
+			a.Underline = false
+		case p == 22:
+			a.Bold, a.Faint = false, false
+		case p == 23:
+			a.Italic = false
+		case p == 25:
+			a.Blink = false
+		case p == 27:
+			a.Inverse = false
+		case p == 28:
+			a.Hidden = false
+		case p == 29:
+			a.Strike = false
+		case p >= 30 && p <= 37:
+			a.FG = Color{Kind: ColorIndexed, Index: uint8(p - 30)}
+		case p == 38 && n > 0:
+			a.FG = subColor(subs)
+		case p == 38:
+			a.FG, i = extColor(params, i)
+		case p == 39:
+			a.FG = Color{}
+		case p >= 40 && p <= 47:
+			a.BG = Color{Kind: ColorIndexed, Index: uint8(p - 40)}
+		case p == 48 && n > 0:
+			a.BG = subColor(subs)
+		case p == 48:
+			a.BG, i = extColor(params, i)
+		case p == 49:
+			a.BG = Color{}
+		case p >= 90 && p <= 97:
+			a.FG = Color{Kind: ColorIndexed, Index: uint8(p - 90 + 8)}
+		case p >= 100 && p <= 107:
+			a.BG = Color{Kind: ColorIndexed, Index: uint8(p - 100 + 8)}
+		}
+		i += n
+	}
+}
+
+func extColor(params []int, i int) (Color, int) {
+	switch param(params, i+1, 0) {
+	case 5:
+		if i+2 < len(params) {
+			return Color{Kind: ColorIndexed, Index: uint8(max(params[i+2], 0))}, i + 2
+		}
+	case 2:
+		if i+4 < len(params) {
+			return Color{
+				Kind: ColorRGB,
+				R:    uint8(max(params[i+2], 0)),
+				G:    uint8(max(params[i+3], 0)),
+				B:    uint8(max(params[i+4], 0)),
+			}, i + 4
+		}
+	}
+	return Color{}, len(params)
+}
+
+// subColor decodes the colon form of an extended color, the parameters
+// after 38 or 48: 5:n, or 2:cs:r:g:b where the colorspace slot cs is
+// usually empty. The 2:r:g:b form some programs emit is accepted too.
+func subColor(subs []int) Color {
+	switch subs[0] {
+	case 5:
+		if len(subs) >= 2 {
+			return Color{Kind: ColorIndexed, Index: uint8(max(subs[1], 0))}
+		}
+	case 2:
+		if len(subs) >= 5 {
+			subs = subs[1:]
+		}
+		if len(subs) >= 4 {
+			return Color{
+				Kind: ColorRGB,
+				R:    uint8(max(subs[1], 0)),
+				G:    uint8(max(subs[2], 0)),
+				B:    uint8(max(subs[3], 0)),
+			}
+		}
+	}
+	return Color{}
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,279 @@
+package vt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func write(s *Screen, data string) {
+	_, _ = s.Write([]byte(data))
+}
+
+func TestScreen_PrintAndWrap(t *testing.T) {
+	s := NewScreen(5, 3)
+	write(s, "hello world")
+
+	want := []string{"hello", " worl", "d"}
+	if got := s.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+	if !s.Wrapped(0) || !s.Wrapped(1) || s.Wrapped(2) {
+		t.Errorf("Wrapped() = %v %v %v, want true true false", s.Wrapped(0), s.Wrapped(1), s.Wrapped(2))
+	}
+	if x, y := s.Cursor(); x != 1 || y != 2 {
+		t.Errorf("Cursor() = (%d, %d), want (1, 2)", x, y)
+	}
+}
+
+func TestScreen_WideRunes(t *testing.T) {
+	s := NewScreen(5, 3)
+	write(s, "日本x")
+
+	want := []Cell{{Rune: '日'}, {}, {Rune: '本'}, {}, {Rune: 'x'}}
+	if got := s.Cells()[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("row 0 = %+v, want %+v", got, want)
+	}
+	if x, y := s.Cursor(); x != 4 || y != 0 {
+		t.Errorf("Cursor() = (%d, %d), want (4, 0)", x, y)
+	}
+
+	write(s, "\r\nabcd日\rz")
+	if got, want := s.Lines(), []string{"日本x", "abcd", "z"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+	if !s.Wrapped(1) {
+		t.Error("Wrapped(1) = false, want true after a wide rune moved to the next line")
+	}
+}
+
+func TestScreen_PendingWrapAtLastColumn(t *testing.T) {
+	s := NewScreen(3, 2)
+	write(s, "abc\r\nd")
+	if got := s.String(); got != "abc\nd" {
+		t.Errorf("String() = %q, want %q", got, "abc\nd")
+	}
+}
+
+func TestScreen_SingleShiftPrints(t *testing.T) {
+	s := NewScreen(5, 1)
+	write(s, "a\x1bOb\x1bNc")
+	if got := s.String(); got != "abc" {
+		t.Errorf("String() = %q, want %q", got, "abc")
+	}
+}
+
+func TestScreen_ScrollOnLineFeed(t *testing.T) {
+	s := NewScreen(4, 2)
+	write(s, "1\r\n2\r\n3")
+	if got := s.Lines(); !reflect.DeepEqual(got, []string{"2", "3"}) {
+		t.Errorf("Lines() = %q, want [2 3]", got)
+	}
+}
+
+func TestScreen_CursorMovementAndErase(t *testing.T) {
+	s := NewScreen(10, 3)
+	write(s, "aaaaaaaaaa\r\nbbbbbbbbbb\r\ncccccccccc")
+	write(s, "\x1b[2;4H\x1b[K")
+	write(s, "\x1b[1;1H\x1b[2P")
+	write(s, "\x1b[3;2H\x1b[1K")
+
+	want := []string{"aaaaaaaa", "bbb", "  cccccccc"}
+	if got := s.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+
+	write(s, "\x1b[2J")
+	if got := s.String(); got != "" {
+		t.Errorf("String() after ED 2 = %q, want empty", got)
+	}
+}
+
+func TestScreen_RelativeMovesClamp(t *testing.T) {
+	s := NewScreen(5, 5)
+	write(s, "\x1b[10B\x1b[10C")
+	if x, y := s.Cursor(); x != 4 || y != 4 {
+		t.Errorf("Cursor() = (%d, %d), want (4, 4)", x, y)
+	}
+	write(s, "\x1b[2A\x1b[3D\x1b[G")
+	if x, y := s.Cursor(); x != 0 || y != 2 {
+		t.Errorf("Cursor() = (%d, %d), want (0, 2)", x, y)
+	}
+}
+
+func TestScreen_SGR(t *testing.T) {
+	s := NewScreen(10, 1)
+	write(s, "\x1b[1;31mR\x1b[38;5;200;48;2;1;2;3mX\x1b[0mN")
+
+	r := s.Cell(0, 0)
+	if !r.Attr.Bold || r.Attr.FG != (Color{Kind: ColorIndexed, Index: 1}) {
+		t.Errorf("cell 0 attr = %+v, want bold red", r.Attr)
+	}
+	x := s.Cell(1, 0)
+	if x.Attr.FG != (Color{Kind: ColorIndexed, Index: 200}) || x.Attr.BG != (Color{Kind: ColorRGB, R: 1, G: 2, B: 3}) {
+		t.Errorf("cell 1 attr = %+v, want 256-color fg and rgb bg", x.Attr)
+	}
+	if n := s.Cell(2, 0); n.Rune != 'N' || n.Attr != (Attr{}) {
+		t.Errorf("cell 2 = %+v, want plain N", n)
+	}
+}
+
+func TestScreen_SGRSubParams(t *testing.T) {
+	s := NewScreen(10, 1)
+	write(s, "\x1b[4:3mA\x1b[4:0mB\x1b[38:2::255:10:20;48:5:200mC\x1b[38:2:0:1:2:3mD\x1b[0;38;2;255;10;20mE")
+
+	if a := s.Cell(0, 0).Attr; !a.Underline || a.Italic {
+		t.Errorf("cell 0 attr = %+v, want underline only", a)
+	}
+	if a := s.Cell(1, 0).Attr; a.Underline {
+		t.Errorf("cell 1 attr = %+v, want underline off", a)
+	}
+	c := s.Cell(2, 0).Attr
+	if c.FG != (Color{Kind: ColorRGB, R: 255, G: 10, B: 20}) || c.BG != (Color{Kind: ColorIndexed, Index: 200}) {
+		t.Errorf("cell 2 attr = %+v, want rgb(255,10,20) fg and 256-color bg", c)
+	}
+	if d := s.Cell(3, 0).Attr; d.FG != (Color{Kind: ColorRGB, R: 1, G: 2, B: 3}) {
+		t.Errorf("cell 3 attr = %+v, want rgb(1,2,3) fg", d)
+	}
+	if e := s.Cell(4, 0).Attr; e != (Attr{FG: Color{Kind: ColorRGB, R: 255, G: 10, B: 20}}) {
+		t.Errorf("cell 4 attr = %+v, want rgb(255,10,20) fg only", e)
+	}
+}
+
+func TestScreen_ScrollRegion(t *testing.T) {
+	s := NewScreen(3, 4)
+	write(s, "top\r\n1\r\n2\r\nbot")
+	write(s, "\x1b[2;3r")
+	if top, bot := s.ScrollRegion(); top != 1 || bot != 2 {
+		t.Fatalf("ScrollRegion() = (%d, %d), want (1, 2)", top, bot)
+	}
+	write(s, "\x1b[3;1H\n3")
+
+	want := []string{"top", "2", "3", "bot"}
+	if got := s.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+
+	write(s, "\x1b[2;1H\x1bM")
+	want = []string{"top", "", "2", "bot"}
+	if got := s.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() after RI = %q, want %q", got, want)
+	}
+}
+
+func TestScreen_InsertDeleteLines(t *testing.T) {
+	s := NewScreen(2, 3)
+	write(s, "a\r\nb\r\nc\x1b[2;1H\x1b[L")
+	if got := s.Lines(); !reflect.DeepEqual(got, []string{"a", "", "b"}) {
+		t.Errorf("Lines() after IL = %q", got)
+	}
+	write(s, "\x1b[M")
+	if got := s.Lines(); !reflect.DeepEqual(got, []string{"a", "b", ""}) {
+		t.Errorf("Lines() after DL = %q", got)
+	}
+}
+
+func TestScreen_AltScreen(t *testing.T) {
+	s := NewScreen(5, 2)
+	write(s, "shell")
+	write(s, "\x1b[?1049h\x1b[Hvim")
+
+	if !s.AltScreen() {
+		t.Fatal("AltScreen() = false after ?1049h")
+	}
+	if got := s.Lines()[0]; got != "vim" {
+		t.Errorf("alt screen line = %q, want %q", got, "vim")
+	}
+
+	write(s, "\x1b[?1049l")
+	if s.AltScreen() {
+		t.Fatal("AltScreen() = true after ?1049l")
+	}
+	if got := s.Lines()[0]; got != "shell" {
+		t.Errorf("primary line = %q, want %q", got, "shell")
+	}
+	if x, y := s.Cursor(); x != 4 || y != 0 {
+		t.Errorf("Cursor() = (%d, %d), want restored (4, 0)", x, y)
+	}
+}
+
+func TestScreen_Modes(t *testing.T) {
+	s := NewScreen(3, 2)
+	write(s, "\x1b[?25l\x1b[?7labcdef")
+	if s.CursorVisible() {
+		t.Error("CursorVisible() = true after ?25l")
+	}
+	if got := s.Lines(); !reflect.DeepEqual(got, []string{"abf", ""}) {
+		t.Errorf("Lines() with autowrap off = %q", got)
+	}
+}
+
+func TestScreen_SaveRestoreCursor(t *testing.T) {
+	s := NewScreen(5, 5)
+	write(s, "\x1b[3;3H\x1b7\x1b[H\x1b8X")
+	if got := s.Lines()[2]; got != "  X" {
+		t.Errorf("line 2 = %q, want %q", got, "  X")
+	}
+}
+
+func TestScreen_TitleAndTabs(t *testing.T) {
+	s := NewScreen(20, 1)
+	write(s, "\x1b]0;my title\x07a\tb")
+	if s.Title() != "my title" {
+		t.Errorf("Title() = %q", s.Title())
+	}
+	if got := s.Lines()[0]; got != "a       b" {
+		t.Errorf("line = %q, want tab to column 8", got)
+	}
+}
+
+func TestScreen_UTF8SplitAcrossWrites(t *testing.T) {
+	s := NewScreen(5, 1)
+	b := []byte("héllo")
+	_, _ = s.Write(b[:2])
+	_, _ = s.Write(b[2:])
+	if got := s.Lines()[0]; got != "héllo" {
+		t.Errorf("line = %q, want %q", got, "héllo")
+	}
+}
+
+func TestScreen_Resize(t *testing.T) {
+	s := NewScreen(5, 3)
+	write(s, "abcde\r\nfghij\r\nkl")
+	s.Resize(3, 2)
+
+	if c, r := s.Size(); c != 3 || r != 2 {
+		t.Fatalf("Size() = (%d, %d), want (3, 2)", c, r)
+	}
+	if got := s.Lines(); !reflect.DeepEqual(got, []string{"abc", "fgh"}) {
+		t.Errorf("Lines() = %q", got)
+	}
+	if x, y := s.Cursor(); x != 2 || y != 1 {
+		t.Errorf("Cursor() = (%d, %d), want clamped (2, 1)", x, y)
+	}
+
+	s.Resize(0, 10)
+	if c, r := s.Size(); c != 3 || r != 2 {
+		t.Errorf("Resize(0, 10) should be ignored, got (%d, %d)", c, r)
+	}
+}
+
+func TestScreen_Reset(t *testing.T) {
+	s := NewScreen(5, 2)
+	write(s, "\x1b[?1049h\x1b[1mab\x1bc")
+	if s.AltScreen() || s.String() != "" {
+		t.Errorf("RIS did not reset the screen: alt=%v text=%q", s.AltScreen(), s.String())
+	}
+}
+
+func TestScreen_CellsIsCopy(t *testing.T) {
+	s := NewScreen(2, 1)
+	write(s, "a")
+	cells := s.Cells()
+	cells[0][0].Rune = 'z'
+	if s.Cell(0, 0).Rune != 'a' {
+		t.Error("Cells() should return a copy")
+	}
+	if c := s.Cell(5, 5); c != blankCell {
+		t.Errorf("Cell() out of range = %+v, want blank", c)
+	}
+}
@@ -0,0 +1,47 @@
+package vt
+
+import (
+	"context"
+	"io"
+
+	"github.com/safedep/ptyx"
+)
+
+var spawnFunc = ptyx.Spawn
+
+// Terminal is a Session whose output also updates a Screen as it is read.
+// Use ptyx.As to reach the optional interfaces of the session it wraps, such
+// as ptyx.ForegroundSession.
+type Terminal struct {
+	ptyx.Session
+	screen *Screen
+	r      io.Reader
+}
+
+func Attach(s ptyx.Session, cols, rows int) *Terminal {
+	scr := NewScreen(cols, rows)
+	return &Terminal{Session: s, screen: scr, r: io.TeeReader(s.PtyReader(), scr)}
+}
+
+func Spawn(ctx context.Context, opts ptyx.SpawnOpts) (*Terminal, error) {
+	s, err := spawnFunc(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return Attach(s, opts.Cols, opts.Rows), nil
+}
+
+func (t *Terminal) Screen() *Screen { return t.screen }
+
+func (t *Terminal) PtyReader() io.Reader { return t.r }
+
+// Unwrap returns the attached session, for ptyx.As.
+func (t *Terminal) Unwrap() ptyx.Session { return t.Session }
+
+func (t *Terminal) Resize(cols, rows int) error {
+	if err := t.Session.Resize(cols, rows); err != nil {
+		return err
+	}
+	t.screen.Resize(cols, rows)
+	return nil
+}
@@ -0,0 +1,96 @@
+package vt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/safedep/ptyx"
+	"github.com/safedep/ptyx/testptyx"
+)
+
+type resizeRecorder struct {
+	*testptyx.MockSession
+	cols, rows int
+	err        error
+}
+
+func (r *resizeRecorder) Resize(cols, rows int) error {
+	r.cols, r.rows = cols, rows
+	return r.err
+}
+
+func TestTerminal_ReadUpdatesScreen(t *testing.T) {
+	term := Attach(testptyx.NewMockSession("\x1b[2J\x1b[Hready"), 10, 2)
+
+	out, err := io.ReadAll(term.PtyReader())
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if string(out) != "\x1b[2J\x1b[Hready" {
+		t.Errorf("reader output = %q, raw output should pass through", out)
+	}
+	if got := term.Screen().Lines()[0]; got != "ready" {
+		t.Errorf("screen line = %q, want %q", got, "ready")
+	}
+}
+
+func TestTerminal_Resize(t *testing.T) {
+	rec := &resizeRecorder{MockSession: testptyx.NewMockSession("")}
+	term := Attach(rec, 80, 24)
+
+	if err := term.Resize(100, 30); err != nil {
+		t.Fatalf("Resize() failed: %v", err)
+	}
+	if rec.cols != 100 || rec.rows != 30 {
+		t.Errorf("session resized to (%d, %d), want (100, 30)", rec.cols, rec.rows)
+	}
+	if c, r := term.Screen().Size(); c != 100 || r != 30 {
+		t.Errorf("screen size = (%d, %d), want (100, 30)", c, r)
+	}
+
+	rec.err = errors.New("resize failed")
+	if err := term.Resize(10, 10); err == nil {
+		t.Fatal("Resize() should propagate session errors")
+	}
+	if c, _ := term.Screen().Size(); c != 100 {
+		t.Errorf("screen should not be resized when the session resize fails")
+	}
+}
+
+func TestSpawn(t *testing.T) {
+	orig := spawnFunc
+	t.Cleanup(func() { spawnFunc = orig })
+
+	spawnFunc = func(ctx context.Context, opts ptyx.SpawnOpts) (ptyx.Session, error) {
+		return testptyx.NewMockSession(""), nil
+	}
+	term, err := Spawn(context.Background(), ptyx.SpawnOpts{Prog: "x", Cols: 40, Rows: 12})
+	if err != nil {
+		t.Fatalf("Spawn() failed: %v", err)
+	}
+	if c, r := term.Screen().Size(); c != 40 || r != 12 {
+		t.Errorf("screen size = (%d, %d), want (40, 12)", c, r)
+	}
+
+	spawnFunc = func(ctx context.Context, opts ptyx.SpawnOpts) (ptyx.Session, error) {
+		return nil, errors.New("spawn failed")
+	}
+	if _, err := Spawn(context.Background(), ptyx.SpawnOpts{Prog: "x"}); err == nil {
+		t.Error("Spawn() should propagate spawn errors")
+	}
+}
+
+func TestTerminal_Unwrap(t *testing.T) {
+	s := testptyx.NewMockSession("")
+	s.Cgroup = &ptyx.CgroupStats{Path: "/sys/fs/cgroup/ptyx-1"}
+	term := Attach(s, 10, 2)
+	cs, ok := ptyx.As[ptyx.CgroupSession](term)
+	if !ok {
+		t.Fatal("As[CgroupSession](term) = false, want the attached session")
+	}
+	if st, err := cs.CgroupStats(); err != nil || st.Path != s.Cgroup.Path {
+		t.Errorf("CgroupStats() = %+v, %v; want the attached session's", st, err)
+	}
+}
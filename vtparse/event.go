@@ -0,0 +1,108 @@
+package vtparse
+
+import (
+	"strconv"
+	"strings"
+)
+
+const Missing = -1
+
+type Event interface {
+	String() string
+}
+
+type Print struct {
+	Text string
+}
+
+type Execute struct {
+	Code byte
+}
+
+type CSI struct {
+	Private byte
+	Params  []int
+	// SubParams has bit i set when Params[i] followed a ':' rather than a
+	// ';', making it part of the parameter before it, as in 38:2::255:0:0.
+	SubParams     uint32
+	Intermediates []byte
+	Final         byte
+}
+
+type ESC struct {
+	Intermediates []byte
+	Final         byte
+}
+
+// SingleShift is SS2 (ESC N) or SS3 (ESC O) with the one character it
+// applies to, e.g. the ESC O A that the up arrow sends in application mode.
+type SingleShift struct {
+	Shift byte // 'N' or 'O'
+	Char  rune
+}
+
+type OSC struct {
+	Data string
+}
+
+type DCS struct {
+	Private       byte
+	Params        []int
+	SubParams     uint32
+	Intermediates []byte
+	Final         byte
+	Data          []byte
+}
+
+func (e Print) String() string   { return e.Text }
+func (e Execute) String() string { return string(rune(e.Code)) }
+
+func (e CSI) String() string {
+	return sequenceString(e.Private, e.Params, e.SubParams, e.Intermediates, e.Final)
+}
+
+func (e CSI) Param(i, def int) int { return param(e.Params, i, def) }
+
+func (e ESC) String() string { return string(e.Intermediates) + string(rune(e.Final)) }
+
+func (e SingleShift) String() string { return string(rune(e.Shift)) + string(e.Char) }
+
+func (e OSC) String() string { return e.Data }
+
+func (e OSC) Command() (cmd, arg string) {
+	cmd, arg, _ = strings.Cut(e.Data, ";")
+	return cmd, arg
+}
+
+func (e DCS) String() string {
+	return sequenceString(e.Private, e.Params, e.SubParams, e.Intermediates, e.Final) + string(e.Data)
+}
+
+func (e DCS) Param(i, def int) int { return param(e.Params, i, def) }
+
+func param(params []int, i, def int) int {
+	if i >= len(params) || params[i] <= 0 {
+		return def
+	}
+	return params[i]
+}
+
+func sequenceString(private byte, params []int, sub uint32, intermediates []byte, final byte) string {
+	var b strings.Builder
+	if private != 0 {
+		b.WriteByte(private)
+	}
+	for i, p := range params {
+		if sub&(1<<i) != 0 {
+			b.WriteByte(':')
+		} else if i > 0 {
+			b.WriteByte(';')
+		}
+		if p != Missing {
+			b.WriteString(strconv.Itoa(p))
+		}
+	}
+	b.Write(intermediates)
+	b.WriteByte(final)
+	return b.String()
+}
@@ -0,0 +1,441 @@
+// Package vtparse implements the DEC/ECMA-48 escape sequence state machine
+// for a UTF-8 byte stream and reports what it finds as typed events. Input
+// may be split at any byte, including in the middle of a sequence or rune.
+//
+// C1 controls are recognised in their UTF-8 form (U+0080-U+009F, e.g.
+// "\u009b" for CSI) and as their 7-bit ESC equivalents. A raw 8-bit byte in
+// 0x80-0x9F is not valid UTF-8 and prints as U+FFFD, as it does in xterm's
+// UTF-8 mode; there is no 8-bit mode.
+package vtparse
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+type state int
+
+const (
+	stateGround state = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateSingleShift
+	stateCSIEntry
+	stateCSIParam
+	stateCSIIntermediate
+	stateCSIIgnore
+	stateOSCString
+	stateDCSEntry
+	stateDCSParam
+	stateDCSIntermediate
+	stateDCSPassthrough
+	stateDCSIgnore
+	stateSOSPMAPCString
+)
+
+const (
+	maxParams     = 32
+	maxParamValue = 65535
+	maxStringLen  = 1 << 20
+)
+
+type Handler func(Event)
+
+type Parser struct {
+	h     Handler
+	state state
+
+	params        []int
+	subParams     uint32
+	subNext       bool
+	curParam      int
+	hasParam      bool
+	private       byte
+	intermediates []byte
+	shift         byte
+	dcsFinal      byte
+	data          []byte
+	strEsc        bool
+
+	utf8Buf []byte
+	text    strings.Builder
+}
+
+func New(h Handler) *Parser {
+	return &Parser{h: h}
+}
+
+func (p *Parser) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		if len(p.utf8Buf) > 0 {
+			c := b[0]
+			if c&0xc0 != 0x80 {
+				p.utf8Buf = p.utf8Buf[:0]
+				p.rune(utf8.RuneError)
+				continue
+			}
+			p.utf8Buf = append(p.utf8Buf, c)
+			b = b[1:]
+			if utf8.FullRune(p.utf8Buf) {
+				r, _ := utf8.DecodeRune(p.utf8Buf)
+				p.utf8Buf = p.utf8Buf[:0]
+				p.rune(r)
+			}
+			continue
+		}
+		if b[0] < 0x80 {
+			p.advance(rune(b[0]))
+			b = b[1:]
+			continue
+		}
+		if !utf8.FullRune(b) {
+			p.utf8Buf = append(p.utf8Buf, b...)
+			break
+		}
+		r, size := utf8.DecodeRune(b)
+		p.rune(r)
+		b = b[size:]
+	}
+	p.flushText()
+	return n, nil
+}
+
+func (p *Parser) Flush() {
+	if len(p.utf8Buf) > 0 {
+		p.utf8Buf = p.utf8Buf[:0]
+		p.rune(utf8.RuneError)
+	}
+	p.flushText()
+}
+
+func (p *Parser) emit(e Event) {
+	p.flushText()
+	p.h(e)
+}
+
+func (p *Parser) flushText() {
+	if p.text.Len() > 0 {
+		s := p.text.String()
+		p.text.Reset()
+		p.h(Print{Text: s})
+	}
+}
+
+func (p *Parser) rune(r rune) {
+	if p.state == stateGround && r >= 0xa0 {
+		p.text.WriteRune(r)
+		return
+	}
+	if p.state == stateSingleShift && r >= 0xa0 {
+		p.emit(SingleShift{Shift: p.shift, Char: r})
+		p.state = stateGround
+		return
+	}
+	if r >= 0x80 && r <= 0x9f {
+		p.c1(byte(r))
+		return
+	}
+	if r >= 0x80 {
+		switch p.state {
+		case stateOSCString, stateDCSPassthrough, stateSOSPMAPCString:
+			p.appendData([]byte(string(r)))
+		}
+		return
+	}
+	p.advance(r)
+}
+
+func (p *Parser) c1(c byte) {
+	p.strEsc = false
+	switch c {
+	case 0x90:
+		p.clear()
+		p.state = stateDCSEntry
+	case 0x9b:
+		p.clear()
+		p.state = stateCSIEntry
+	case 0x9d:
+		p.data = p.data[:0]
+		p.state = stateOSCString
+	case 0x98, 0x9e, 0x9f:
+		p.state = stateSOSPMAPCString
+	case 0x9c:
+		p.terminateString()
+	case 0x8e, 0x8f:
+		p.shift = c - 0x40
+		p.state = stateSingleShift
+	default:
+		p.emit(Execute{Code: c})
+		p.state = stateGround
+	}
+}
+
+func (p *Parser) clear() {
+	p.params = p.params[:0]
+	p.subParams = 0
+	p.subNext = false
+	p.curParam = 0
+	p.hasParam = false
+	p.private = 0
+	p.intermediates = p.intermediates[:0]
+	p.data = p.data[:0]
+}
+
+func (p *Parser) appendData(b []byte) {
+	if len(p.data)+len(b) <= maxStringLen {
+		p.data = append(p.data, b...)
+	}
+}
+
+func (p *Parser) terminateString() {
+	switch p.state {
+	case stateOSCString:
+		p.emit(OSC{Data: string(p.data)})
+	case stateDCSPassthrough:
+		p.emit(DCS{
+			Private:       p.private,
+			Params:        append([]int(nil), p.params...),
+			SubParams:     p.subParams,
+			Intermediates: append([]byte(nil), p.intermediates...),
+			Final:         p.dcsFinal,
+			Data:          append([]byte(nil), p.data...),
+		})
+	}
+	p.state = stateGround
+}
+
+func (p *Parser) isString() bool {
+	switch p.state {
+	case stateOSCString, stateDCSEntry, stateDCSParam, stateDCSIntermediate,
+		stateDCSPassthrough, stateDCSIgnore, stateSOSPMAPCString:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) advance(r rune) {
+	c := byte(r)
+
+	if p.strEsc {
+		p.strEsc = false
+		if c == '\\' {
+			p.terminateString()
+			return
+		}
+		p.terminateString()
+		p.clear()
+		p.state = stateEscape
+	}
+
+	switch {
+	case c == 0x18 || c == 0x1a:
+		p.emit(Execute{Code: c})
+		p.state = stateGround
+		return
+	case c == 0x1b:
+		if p.isString() {
+			p.strEsc = true
+			return
+		}
+		p.clear()
+		p.state = stateEscape
+		return
+	}
+
+	switch p.state {
+	case stateGround:
+		switch {
+		case c < 0x20:
+			p.emit(Execute{Code: c})
+		case c < 0x7f:
+			p.text.WriteByte(c)
+		}
+
+	case stateEscape:
+		switch {
+		case c < 0x20:
+			p.emit(Execute{Code: c})
+		case c <= 0x2f:
+			p.intermediates = append(p.intermediates, c)
+			p.state = stateEscapeIntermediate
+		case c == '[':
+			p.state = stateCSIEntry
+		case c == ']':
+			p.data = p.data[:0]
+			p.state = stateOSCString
+		case c == 'P':
+			p.state = stateDCSEntry
+		case c == 'X' || c == '^' || c == '_':
+			p.state = stateSOSPMAPCString
+		case c == 'N' || c == 'O':
+			p.shift = c
+			p.state = stateSingleShift
+		case c < 0x7f:
+			p.emit(ESC{Final: c})
+			p.state = stateGround
+		}
+
+	case stateEscapeIntermediate:
+		switch {
+		case c < 0x20:
+			p.emit(Execute{Code: c})
+		case c <= 0x2f:
+			p.collect(c)
+		case c < 0x7f:
+			p.emit(ESC{Intermediates: append([]byte(nil), p.intermediates...), Final: c})
+			p.state = stateGround
+		}
+
+	case stateSingleShift:
+		switch {
+		case c < 0x20:
+			p.emit(Execute{Code: c})
+		case c < 0x7f:
+			p.emit(SingleShift{Shift: p.shift, Char: r})
+			p.state = stateGround
+		}
+
+	case stateCSIEntry, stateCSIParam:
+		switch {
+		case c < 0x20:
+			p.emit(Execute{Code: c})
+		case c < 0x7f && p.param(c, stateCSIParam, stateCSIIgnore):
+		case c <= 0x2f:
+			p.collect(c)
+			p.state = stateCSIIntermediate
+		case c >= 0x40 && c <= 0x7e:
+			p.dispatchCSI(c)
+		}
+
+	case stateCSIIntermediate:
+		switch {
+		case c < 0x20:
+			p.emit(Execute{Code: c})
+		case c <= 0x2f:
+			p.collect(c)
+		case c <= 0x3f:
+			p.state = stateCSIIgnore
+		case c <= 0x7e:
+			p.dispatchCSI(c)
+		}
+
+	case stateCSIIgnore:
+		switch {
+		case c < 0x20:
+			p.emit(Execute{Code: c})
+		case c >= 0x40 && c <= 0x7e:
+			p.state = stateGround
+		}
+
+	case stateOSCString:
+		switch {
+		case c == 0x07:
+			p.terminateString()
+		case c >= 0x20 && c < 0x7f:
+			p.appendData([]byte{c})
+		}
+
+	case stateDCSEntry, stateDCSParam:
+		switch {
+		case c < 0x20:
+		case c < 0x7f && p.param(c, stateDCSParam, stateDCSIgnore):
+		case c <= 0x2f:
+			p.collect(c)
+			p.state = stateDCSIntermediate
+		case c >= 0x40 && c <= 0x7e:
+			p.hookDCS(c)
+		}
+
+	case stateDCSIntermediate:
+		switch {
+		case c < 0x20:
+		case c <= 0x2f:
+			p.collect(c)
+		case c <= 0x3f:
+			p.state = stateDCSIgnore
+		case c <= 0x7e:
+			p.hookDCS(c)
+		}
+
+	case stateDCSPassthrough:
+		if c != 0x7f {
+			p.appendData([]byte{c})
+		}
+
+	case stateDCSIgnore, stateSOSPMAPCString:
+	}
+}
+
+func (p *Parser) param(c byte, next, ignore state) bool {
+	switch {
+	case c >= '0' && c <= '9':
+		p.curParam = min(p.curParam*10+int(c-'0'), maxParamValue)
+		p.hasParam = true
+		p.state = next
+	case c == ';':
+		p.pushParam()
+		p.state = next
+	case c == ':':
+		p.pushParam()
+		p.subNext = true
+		p.state = next
+	case c >= '<' && c <= '?':
+		if p.state == stateCSIEntry || p.state == stateDCSEntry {
+			p.private = c
+			p.state = next
+		} else {
+			p.state = ignore
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (p *Parser) collect(c byte) {
+	if len(p.intermediates) < 4 {
+		p.intermediates = append(p.intermediates, c)
+	}
+}
+
+func (p *Parser) pushParam() {
+	if len(p.params) < maxParams {
+		if p.subNext {
+			p.subParams |= 1 << len(p.params)
+		}
+		if p.hasParam {
+			p.params = append(p.params, p.curParam)
+		} else {
+			p.params = append(p.params, Missing)
+		}
+	}
+	p.curParam = 0
+	p.hasParam = false
+	p.subNext = false
+}
+
+func (p *Parser) finishParams() {
+	if p.hasParam || len(p.params) > 0 {
+		p.pushParam()
+	}
+}
+
+func (p *Parser) dispatchCSI(final byte) {
+	p.finishParams()
+	p.emit(CSI{
+		Private:       p.private,
+		Params:        append([]int(nil), p.params...),
+		SubParams:     p.subParams,
+		Intermediates: append([]byte(nil), p.intermediates...),
+		Final:         final,
+	})
+	p.state = stateGround
+}
+
+func (p *Parser) hookDCS(final byte) {
+	p.finishParams()
+	p.dcsFinal = final
+	p.data = p.data[:0]
+	p.state = stateDCSPassthrough
+}
@@ -0,0 +1,235 @@
+package vtparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func parseAll(chunks ...string) []Event {
+	var events []Event
+	p := New(func(e Event) { events = append(events, e) })
+	for _, c := range chunks {
+		_, _ = p.Write([]byte(c))
+	}
+	p.Flush()
+	return events
+}
+
+func mergePrints(events []Event) []Event {
+	var out []Event
+	for _, e := range events {
+		if pr, ok := e.(Print); ok && len(out) > 0 {
+			if last, ok := out[len(out)-1].(Print); ok {
+				out[len(out)-1] = Print{Text: last.Text + pr.Text}
+				continue
+			}
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Event
+	}{
+		{
+			name:  "Text and controls",
+			input: "hi\r\n\x07",
+			want:  []Event{Print{"hi"}, Execute{'\r'}, Execute{'\n'}, Execute{0x07}},
+		},
+		{
+			name:  "CSI with params",
+			input: "\x1b[1;31mX",
+			want:  []Event{CSI{Params: []int{1, 31}, Final: 'm'}, Print{"X"}},
+		},
+		{
+			name:  "CSI private and missing params",
+			input: "\x1b[?25l\x1b[;5H\x1b[m",
+			want: []Event{
+				CSI{Private: '?', Params: []int{25}, Final: 'l'},
+				CSI{Params: []int{Missing, 5}, Final: 'H'},
+				CSI{Final: 'm'},
+			},
+		},
+		{
+			name:  "CSI intermediate",
+			input: "\x1b[2 q",
+			want:  []Event{CSI{Params: []int{2}, Intermediates: []byte{' '}, Final: 'q'}},
+		},
+		{
+			name:  "CSI with embedded control",
+			input: "\x1b[1\n;2H",
+			want:  []Event{Execute{'\n'}, CSI{Params: []int{1, 2}, Final: 'H'}},
+		},
+		{
+			name:  "Malformed CSI is ignored",
+			input: "\x1b[1?2hok",
+			want:  []Event{Print{"ok"}},
+		},
+		{
+			name:  "ESC dispatch",
+			input: "\x1b7\x1b(B\x1bM",
+			want:  []Event{ESC{Final: '7'}, ESC{Intermediates: []byte{'('}, Final: 'B'}, ESC{Final: 'M'}},
+		},
+		{
+			name:  "OSC terminated by BEL",
+			input: "\x1b]0;title\x07",
+			want:  []Event{OSC{Data: "0;title"}},
+		},
+		{
+			name:  "OSC terminated by ST",
+			input: "\x1b]2;héllo\x1b\\after",
+			want:  []Event{OSC{Data: "2;héllo"}, Print{"after"}},
+		},
+		{
+			name:  "DCS passthrough",
+			input: "\x1bP1$r0m\x1b\\",
+			want:  []Event{DCS{Params: []int{1}, Intermediates: []byte{'$'}, Final: 'r', Data: []byte("0m")}},
+		},
+		{
+			name:  "SOS PM APC are swallowed",
+			input: "a\x1b_apc data\x1b\\b\x1b^pm\x1b\\c\x1bXsos\x1b\\d",
+			want:  []Event{Print{"abcd"}},
+		},
+		{
+			name:  "C1 controls",
+			input: "\u009b31m\u0085\u009d0;t\u009c",
+			want: []Event{
+				CSI{Params: []int{31}, Final: 'm'},
+				Execute{0x85},
+				OSC{Data: "0;t"},
+			},
+		},
+		{
+			name:  "Raw 8-bit C1 is invalid UTF-8",
+			input: "\x9b31m\x85",
+			want:  []Event{Print{"\ufffd31m\ufffd"}},
+		},
+		{
+			name:  "CAN aborts a sequence",
+			input: "\x1b[12\x18x",
+			want:  []Event{Execute{0x18}, Print{"x"}},
+		},
+		{
+			name:  "ESC restarts a sequence",
+			input: "\x1b[12\x1b[3m",
+			want:  []Event{CSI{Params: []int{3}, Final: 'm'}},
+		},
+		{
+			name:  "Invalid UTF-8",
+			input: "a\xffb",
+			want:  []Event{Print{"a�b"}},
+		},
+		{
+			name:  "DEL is ignored",
+			input: "a\x7fb",
+			want:  []Event{Print{"ab"}},
+		},
+		{
+			name:  "SS3 consumes its character",
+			input: "\x1bOAx\x1bN\x1bOP",
+			want:  []Event{SingleShift{'O', 'A'}, Print{"x"}, SingleShift{'O', 'P'}},
+		},
+		{
+			name:  "C1 single shifts",
+			input: "\u008eé\u008f\rB",
+			want:  []Event{SingleShift{'N', 'é'}, Execute{'\r'}, SingleShift{'O', 'B'}},
+		},
+		{
+			name:  "Colon sub-parameters",
+			input: "\x1b[38:2::255:0:0;1m",
+			want:  []Event{CSI{Params: []int{38, 2, Missing, 255, 0, 0, 1}, SubParams: 0b111110, Final: 'm'}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergePrints(parseAll(tt.input))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_ArbitraryChunkBoundaries(t *testing.T) {
+	input := "héllo \x1b[38;5;200mwörld\x1b]0;tïtle\x1b\\\u009b1m\x1bP+q\x1b\\✓\r\n"
+	want := mergePrints(parseAll(input))
+
+	for size := 1; size <= 4; size++ {
+		var chunks []string
+		b := []byte(input)
+		for len(b) > 0 {
+			n := min(size, len(b))
+			chunks = append(chunks, string(b[:n]))
+			b = b[n:]
+		}
+		if got := mergePrints(parseAll(chunks...)); !reflect.DeepEqual(got, want) {
+			t.Errorf("chunk size %d: events = %#v, want %#v", size, got, want)
+		}
+	}
+}
+
+func TestParser_FlushIncompleteRune(t *testing.T) {
+	got := parseAll("a\xe2\x9c")
+	want := []Event{Print{"a"}, Print{"�"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %#v, want %#v", got, want)
+	}
+}
+
+func TestParser_ParamLimits(t *testing.T) {
+	var input = "\x1b["
+	for i := 0; i < 40; i++ {
+		input += "1;"
+	}
+	input += "99999999m"
+	events := parseAll(input)
+	csi, ok := events[0].(CSI)
+	if !ok || len(csi.Params) != maxParams {
+		t.Fatalf("events = %#v, want CSI with %d params", events, maxParams)
+	}
+
+	events = parseAll("\x1b[99999999m")
+	if csi := events[0].(CSI); csi.Params[0] != maxParamValue {
+		t.Errorf("param = %d, want clamp to %d", csi.Params[0], maxParamValue)
+	}
+}
+
+func TestEventStrings(t *testing.T) {
+	tests := []struct {
+		e    Event
+		want string
+	}{
+		{Print{"x"}, "x"},
+		{Execute{'\n'}, "\n"},
+		{CSI{Private: '?', Params: []int{Missing, 25}, Final: 'h'}, "?;25h"},
+		{ESC{Intermediates: []byte{'('}, Final: 'B'}, "(B"},
+		{CSI{Params: []int{4, 3, 1}, SubParams: 0b10, Final: 'm'}, "4:3;1m"},
+		{SingleShift{'O', 'A'}, "OA"},
+		{OSC{Data: "0;t"}, "0;t"},
+		{DCS{Params: []int{1}, Intermediates: []byte{'$'}, Final: 'r', Data: []byte("0m")}, "1$r0m"},
+	}
+	for _, tt := range tests {
+		if got := tt.e.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.e, got, tt.want)
+		}
+	}
+}
+
+func TestParamHelpers(t *testing.T) {
+	csi := CSI{Params: []int{Missing, 0, 7}}
+	if csi.Param(0, 1) != 1 || csi.Param(1, 1) != 1 || csi.Param(2, 1) != 7 || csi.Param(3, 4) != 4 {
+		t.Errorf("CSI.Param() returned unexpected defaults")
+	}
+	if (DCS{Params: []int{3}}).Param(0, 1) != 3 {
+		t.Errorf("DCS.Param() = %d, want 3", (DCS{Params: []int{3}}).Param(0, 1))
+	}
+	cmd, arg := OSC{Data: "2;a;b"}.Command()
+	if cmd != "2" || arg != "a;b" {
+		t.Errorf("OSC.Command() = (%q, %q), want (2, a;b)", cmd, arg)
+	}
+}